@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+const (
+	// adminPageSize is the number of snippets shown per page in the admin area.
+	adminPageSize = 50
+
+	// auditPageSize is the number of audit events shown on the audit log
+	// page; CSV exports aren't limited.
+	auditPageSize = 500
+)
+
+type adminStats struct {
+	Users       int
+	Snippets    int
+	ActiveUsers int
+	PerDay      []models.DayCount
+}
+
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	var stats adminStats
+	var err error
+
+	stats.Users, err = app.users.Count(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	stats.Snippets, err = app.snippets.Count(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	stats.ActiveUsers, err = app.userSessions.ActiveUsers(r.Context(), 24*time.Hour)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	stats.PerDay, err = app.snippets.PerDay(r.Context(), 30)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.AdminStats = stats
+
+	app.render(w, r, http.StatusOK, "admin.tmpl.html", data)
+}
+
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	search := r.URL.Query().Get("q")
+
+	users, err := app.users.List(r.Context(), search)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Users = users
+	data.Search = search
+
+	app.render(w, r, http.StatusOK, "admin-users.tmpl.html", data)
+}
+
+func (app *application) adminUserDisablePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// Stop admins from locking themselves out by accident
+	if id == app.authenticatedUserID(r) {
+		app.sessionManager.Put(r.Context(), "flash", "You can't disable your own account.")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	err = app.users.SetDisabled(r.Context(), id, true)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// authenticate would reject their sessions anyway, but there's no
+	// reason to keep them around
+	err = app.revokeAllSessions(r.Context(), id, "")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.audit(r, models.EventAdminAction, map[string]any{"action": "user.disable", "user_id": id})
+
+	app.sessionManager.Put(r.Context(), "flash", "User disabled.")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) adminUserEnablePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	err = app.users.SetDisabled(r.Context(), id, false)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.audit(r, models.EventAdminAction, map[string]any{"action": "user.enable", "user_id": id})
+
+	app.sessionManager.Put(r.Context(), "flash", "User enabled.")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) adminUserLogoutPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// Leave the admin's own current session alone
+	err = app.revokeAllSessions(r.Context(), id, app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.audit(r, models.EventAdminAction, map[string]any{"action": "user.logout", "user_id": id})
+
+	app.sessionManager.Put(r.Context(), "flash", "User logged out everywhere.")
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// Fetch one extra snippet, to find out whether there's another page
+	snippets, err := app.snippets.All(r.Context(), adminPageSize+1, (page-1)*adminPageSize)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.PrevPage = page - 1
+	if len(snippets) > adminPageSize {
+		data.NextPage = page + 1
+		snippets = snippets[:adminPageSize]
+	}
+	data.Snippets = snippets
+
+	app.render(w, r, http.StatusOK, "admin-snippets.tmpl.html", data)
+}
+
+func (app *application) adminSnippetHidePost(w http.ResponseWriter, r *http.Request) {
+	app.adminSetSnippetHidden(w, r, true)
+}
+
+func (app *application) adminSnippetUnhidePost(w http.ResponseWriter, r *http.Request) {
+	app.adminSetSnippetHidden(w, r, false)
+}
+
+func (app *application) adminSetSnippetHidden(w http.ResponseWriter, r *http.Request, hidden bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	err = app.snippets.SetHidden(r.Context(), id, hidden)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if hidden {
+		app.audit(r, models.EventAdminAction, map[string]any{"action": "snippet.hide", "snippet_id": id})
+		app.sessionManager.Put(r.Context(), "flash", "Snippet hidden.")
+	} else {
+		app.audit(r, models.EventAdminAction, map[string]any{"action": "snippet.unhide", "snippet_id": id})
+		app.sessionManager.Put(r.Context(), "flash", "Snippet visible again.")
+	}
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
+
+func (app *application) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	err = app.snippets.Delete(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, r, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, models.EventSnippetDelete, map[string]any{"snippet_id": id})
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet deleted.")
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
+
+// auditFilterForm holds the audit log filters, which are passed in the
+// query string so that filtered views can be bookmarked and exported.
+type auditFilterForm struct {
+	Type    string
+	ActorID string
+	Since   string // YYYY-MM-DD
+	Until   string // YYYY-MM-DD, inclusive
+}
+
+// parseAuditFilter() reads the audit log filters from the query string.
+// Values that can't be parsed are ignored.
+func parseAuditFilter(query url.Values) (auditFilterForm, models.AuditFilter) {
+	form := auditFilterForm{
+		Type:    query.Get("type"),
+		ActorID: query.Get("actor"),
+		Since:   query.Get("since"),
+		Until:   query.Get("until"),
+	}
+
+	filter := models.AuditFilter{Type: form.Type}
+	filter.ActorID, _ = strconv.Atoi(form.ActorID)
+
+	if t, err := time.Parse(time.DateOnly, form.Since); err == nil {
+		filter.Since = t
+	}
+	if t, err := time.Parse(time.DateOnly, form.Until); err == nil {
+		filter.Until = t.AddDate(0, 0, 1)
+	}
+
+	return form, filter
+}
+
+func (app *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+	form, filter := parseAuditFilter(r.URL.Query())
+	filter.Limit = auditPageSize
+
+	events, err := app.auditLog.List(r.Context(), filter)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.AuditEvents = events
+	data.AuditEventTypes = models.AuditEventTypes
+
+	app.render(w, r, http.StatusOK, "admin-audit.tmpl.html", data)
+}
+
+// csvSafe() stops a spreadsheet treating a CSV cell as a formula. The user
+// agent and details of audit events come from clients, who could otherwise
+// run formulas on the computer of an admin who opens the export.
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func (app *application) adminAuditExport(w http.ResponseWriter, r *http.Request) {
+	_, filter := parseAuditFilter(r.URL.Query())
+
+	events, err := app.auditLog.List(r.Context(), filter)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-log.csv"`)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "time", "type", "actor_id", "ip", "user_agent", "details"})
+
+	for _, e := range events {
+		actor := ""
+		if e.ActorID != 0 {
+			actor = strconv.Itoa(e.ActorID)
+		}
+
+		cw.Write([]string{
+			strconv.Itoa(e.ID),
+			e.Created.UTC().Format(time.RFC3339),
+			e.Type,
+			actor,
+			csvSafe(e.IP),
+			csvSafe(e.UserAgent),
+			csvSafe(e.Details),
+		})
+	}
+
+	// The headers have already been sent, so all we can do is log the error
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		app.logger.ErrorContext(r.Context(), err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"github.com/rhysmah/snippet-box/internal/models"
+	"github.com/rhysmah/snippet-box/internal/models/mocks"
+)
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"", ""},
+		{"Mozilla/5.0", "Mozilla/5.0"},
+		{`{"email":"=1+1"}`, `{"email":"=1+1"}`},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cell, func(t *testing.T) {
+			assert.Equal(t, csvSafe(tt.cell), tt.want)
+		})
+	}
+}
+
+// newAdminTestServer() returns a test server with Carol, the admin, logged in.
+func newAdminTestServer(t *testing.T) (*application, *testServer) {
+	t.Helper()
+
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "carol@example.com", mocks.MockPassword)
+
+	return app, ts
+}
+
+// adminPost() submits one of the admin area's buttons.
+func (ts *testServer) adminPost(t *testing.T, urlPath string) (int, http.Header) {
+	t.Helper()
+
+	_, _, body := ts.get(t, "/admin/users")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := ts.postForm(t, urlPath, form)
+	return code, header
+}
+
+// lastAuditEvent() returns the most recent audit event of the given type.
+func lastAuditEvent(t *testing.T, app *application, eventType string) (models.AuditEvent, bool) {
+	t.Helper()
+
+	events, err := app.auditLog.List(t.Context(), models.AuditFilter{Type: eventType, Limit: 1})
+	assert.NilError(t, err)
+
+	if len(events) == 0 {
+		return models.AuditEvent{}, false
+	}
+	return events[0], true
+}
+
+func TestAdminUserActions(t *testing.T) {
+	t.Run("Disable", func(t *testing.T) {
+		app, ts := newAdminTestServer(t)
+
+		alice := newTestServer(t, app.routes())
+		alice.login(t, "alice@example.com", mocks.MockPassword)
+
+		code, header := ts.adminPost(t, "/admin/users/1/disable")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/admin/users")
+
+		exists, err := app.users.Exists(t.Context(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, false)
+
+		sessions, err := app.userSessions.All(t.Context(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(sessions), 0)
+
+		e, ok := lastAuditEvent(t, app, models.EventAdminAction)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, e.ActorID, 3)
+		assert.Equal(t, e.Details, `{"action":"user.disable","user_id":1}`)
+	})
+
+	t.Run("Disable self", func(t *testing.T) {
+		app, ts := newAdminTestServer(t)
+
+		code, _ := ts.adminPost(t, "/admin/users/3/disable")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, _, body := ts.get(t, "/admin/users")
+		assert.StringContains(t, body, "You can&#39;t disable your own account.")
+
+		exists, err := app.users.Exists(t.Context(), 3)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, true)
+
+		_, ok := lastAuditEvent(t, app, models.EventAdminAction)
+		assert.Equal(t, ok, false)
+	})
+
+	t.Run("Enable", func(t *testing.T) {
+		app, ts := newAdminTestServer(t)
+
+		code, _ := ts.adminPost(t, "/admin/users/4/enable")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		exists, err := app.users.Exists(t.Context(), 4)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, true)
+
+		e, ok := lastAuditEvent(t, app, models.EventAdminAction)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, e.Details, `{"action":"user.enable","user_id":4}`)
+	})
+
+	t.Run("Force logout", func(t *testing.T) {
+		app, ts := newAdminTestServer(t)
+
+		alice := newTestServer(t, app.routes())
+		alice.login(t, "alice@example.com", mocks.MockPassword)
+
+		code, _ := ts.adminPost(t, "/admin/users/1/logout")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		code, header, _ := alice.get(t, "/account/sessions")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+
+		// Alice can log straight back in; she's been logged out, not disabled
+		exists, err := app.users.Exists(t.Context(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, true)
+
+		// The admin stays logged in
+		code, _, _ = ts.get(t, "/admin/users")
+		assert.Equal(t, code, http.StatusOK)
+
+		e, ok := lastAuditEvent(t, app, models.EventAdminAction)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, e.Details, `{"action":"user.logout","user_id":1}`)
+	})
+}
+
+func TestAdminSnippetActions(t *testing.T) {
+	id := mocks.MockSnippet.ID
+
+	t.Run("Hide", func(t *testing.T) {
+		app, ts := newAdminTestServer(t)
+
+		code, header := ts.adminPost(t, fmt.Sprintf("/admin/snippets/%d/hide", id))
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/admin/snippets")
+
+		_, err := app.snippets.Get(t.Context(), id)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		e, ok := lastAuditEvent(t, app, models.EventAdminAction)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, e.Details, fmt.Sprintf(`{"action":"snippet.hide","snippet_id":%d}`, id))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		app, ts := newAdminTestServer(t)
+
+		code, _ := ts.adminPost(t, fmt.Sprintf("/admin/snippets/%d/delete", id))
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		e, ok := lastAuditEvent(t, app, models.EventSnippetDelete)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, e.ActorID, 3)
+		assert.Equal(t, e.Details, fmt.Sprintf(`{"snippet_id":%d}`, id))
+
+		// It's gone, so a second attempt finds nothing
+		code, _ = ts.adminPost(t, fmt.Sprintf("/admin/snippets/%d/delete", id))
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestAdminAuditExport(t *testing.T) {
+	app, ts := newAdminTestServer(t)
+
+	err := app.auditLog.Insert(t.Context(), models.AuditEvent{
+		Type:      models.EventLoginFailed,
+		IP:        "192.0.2.1",
+		UserAgent: `=HYPERLINK("http://example.com")`,
+		Details:   `-1`,
+	})
+	assert.NilError(t, err)
+
+	code, header, body := ts.get(t, "/admin/audit/export?type="+models.EventLoginFailed)
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "text/csv; charset=utf-8")
+
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	assert.NilError(t, err)
+
+	// Carol's own login doesn't count as a failure, so there's only the one
+	assert.Equal(t, len(records), 2)
+	assert.Equal(t, strings.Join(records[0], ","), "id,time,type,actor_id,ip,user_agent,details")
+
+	row := records[1]
+	assert.Equal(t, row[2], models.EventLoginFailed)
+	assert.Equal(t, row[3], "")
+	assert.Equal(t, row[5], `'=HYPERLINK("http://example.com")`)
+	assert.Equal(t, row[6], `'-1`)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rhysmah/snippet-box/internal/config"
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+// bootstrapAdmin() makes sure there's a first admin to log in with. If
+// admin.Email is set, the user with that email is made an admin; if there's
+// no such user yet, they're created with admin.Password and admin.Name.
+// These are usually set through the environment:
+//
+//	SNIPPETBOX_ADMIN_EMAIL     email of the user to make an admin
+//	SNIPPETBOX_ADMIN_PASSWORD  if set, and no such user exists yet, create them
+//	SNIPPETBOX_ADMIN_NAME      name for a newly created admin (default "Admin")
+//
+// It's safe to run on every start: if no email is set, or there's already an
+// admin, it does nothing. That way a SNIPPETBOX_ADMIN_EMAIL left in the
+// environment can't hand the role back to someone it's since been taken from.
+func (app *application) bootstrapAdmin(ctx context.Context, admin config.AdminConfig) error {
+	if admin.Email == "" {
+		return nil
+	}
+
+	admins, err := app.users.CountWithRole(ctx, models.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("bootstrap admin: %w", err)
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	id, err := app.users.IDForEmail(ctx, admin.Email)
+	if errors.Is(err, models.ErrNoRecord) {
+		if admin.Password == "" {
+			return fmt.Errorf("bootstrap admin: no user with email %q; set SNIPPETBOX_ADMIN_PASSWORD to create one", admin.Email)
+		}
+
+		err = app.users.Insert(ctx, admin.Name, admin.Email, admin.Password)
+		if err != nil {
+			return fmt.Errorf("bootstrap admin: %w", err)
+		}
+
+		id, err = app.users.IDForEmail(ctx, admin.Email)
+	}
+	if err != nil {
+		return fmt.Errorf("bootstrap admin: %w", err)
+	}
+
+	err = app.users.AddRole(ctx, id, models.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("bootstrap admin: %w", err)
+	}
+
+	app.logger.Info("granted admin role", "email", admin.Email)
+	return nil
+}
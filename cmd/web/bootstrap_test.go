@@ -0,0 +1,99 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"github.com/rhysmah/snippet-box/internal/config"
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+func TestBootstrapAdmin(t *testing.T) {
+	isAdmin := func(t *testing.T, app *application, email string) bool {
+		t.Helper()
+
+		id, err := app.users.IDForEmail(t.Context(), email)
+		assert.NilError(t, err)
+
+		roles, err := app.users.Roles(t.Context(), id)
+		assert.NilError(t, err)
+
+		return slices.Contains(roles, models.RoleAdmin)
+	}
+
+	// Without Carol, the mock users have no admin
+	newApp := func(t *testing.T) *application {
+		t.Helper()
+
+		app := newTestApplication(t)
+		err := app.users.RemoveRole(t.Context(), 3, models.RoleAdmin)
+		assert.NilError(t, err)
+
+		return app
+	}
+
+	t.Run("No email", func(t *testing.T) {
+		app := newApp(t)
+
+		err := app.bootstrapAdmin(t.Context(), config.AdminConfig{})
+		assert.NilError(t, err)
+
+		admins, err := app.users.CountWithRole(t.Context(), models.RoleAdmin)
+		assert.NilError(t, err)
+		assert.Equal(t, admins, 0)
+	})
+
+	t.Run("Promote existing user", func(t *testing.T) {
+		app := newApp(t)
+		admin := config.AdminConfig{Email: "alice@example.com"}
+
+		// Running on every start changes nothing after the first
+		for range 2 {
+			err := app.bootstrapAdmin(t.Context(), admin)
+			assert.NilError(t, err)
+			assert.Equal(t, isAdmin(t, app, "alice@example.com"), true)
+		}
+
+		count, err := app.users.Count(t.Context())
+		assert.NilError(t, err)
+		assert.Equal(t, count, 4)
+	})
+
+	t.Run("Create new user", func(t *testing.T) {
+		app := newApp(t)
+		admin := config.AdminConfig{Email: "erin@example.com", Password: "pa$$word", Name: "Erin"}
+
+		for range 2 {
+			err := app.bootstrapAdmin(t.Context(), admin)
+			assert.NilError(t, err)
+			assert.Equal(t, isAdmin(t, app, "erin@example.com"), true)
+		}
+
+		count, err := app.users.Count(t.Context())
+		assert.NilError(t, err)
+		assert.Equal(t, count, 5)
+	})
+
+	t.Run("New user without a password", func(t *testing.T) {
+		app := newApp(t)
+
+		err := app.bootstrapAdmin(t.Context(), config.AdminConfig{Email: "erin@example.com"})
+		assert.Equal(t, err != nil, true)
+	})
+
+	t.Run("Admin already exists", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		err := app.bootstrapAdmin(t.Context(), config.AdminConfig{Email: "alice@example.com"})
+		assert.NilError(t, err)
+		assert.Equal(t, isAdmin(t, app, "alice@example.com"), false)
+
+		// Nor is anyone created
+		err = app.bootstrapAdmin(t.Context(), config.AdminConfig{Email: "erin@example.com", Password: "pa$$word"})
+		assert.NilError(t, err)
+
+		_, err = app.users.IDForEmail(t.Context(), "erin@example.com")
+		assert.Equal(t, err, models.ErrNoRecord)
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/justinas/nosurf"
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+// serverStarted goes into every snippet page's validators, so pages cached
+// by browsers are refreshed after a deploy which changes the templates.
+var serverStarted = time.Now().UTC().Truncate(time.Second)
+
+// cacheSnippet() sets the caching headers for a response showing a snippet,
+// and sends a 304 Not Modified if the client's cached copy is still current;
+// it reports whether it did, in which case the handler has nothing more to
+// do.
+//
+// Snippet pages carry the visitor's CSRF token, so only the visitor's own
+// browser may keep them, and must check with us before reusing them. Pages
+// for logged-in users, or showing a flash message, aren't kept at all.
+func (app *application) cacheSnippet(w http.ResponseWriter, r *http.Request, s models.Snippet) bool {
+	if app.isAuthenticated(r) || app.sessionManager.Exists(r.Context(), "flash") {
+		w.Header().Set("Cache-Control", "private, no-store")
+		return false
+	}
+
+	// compress() weakens the ETags of the responses it compresses, so do
+	// the same here for clients whose responses it will compress, or the
+	// 304s they're sent would carry a different ETag to the 200s
+	etag := snippetETag(r, s)
+	if r.Method != http.MethodHead && negotiateEncoding(r.Header.Get("Accept-Encoding")) != "" {
+		etag = "W/" + etag
+	}
+
+	modified := s.Updated
+	if serverStarted.After(modified) {
+		modified = serverStarted
+	}
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+
+	if notModified(r, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// snippetETag() identifies the version of a snippet page which the client
+// would be sent. It changes when the snippet does, when the server restarts,
+// and when the visitor's CSRF cookie changes, as the page embeds a token
+// derived from it.
+func snippetETag(r *http.Request, s models.Snippet) string {
+	var csrfCookie string
+	if c, err := r.Cookie(nosurf.CookieName); err == nil {
+		csrfCookie = c.Value
+	}
+
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d|%d|%d|%s", s.ID, s.Updated.UnixNano(), serverStarted.Unix(), csrfCookie))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// notModified() reports whether the request's If-None-Match header, or
+// failing that its If-Modified-Since header, shows the client already has
+// the current version of the response.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	// Last-Modified only has whole seconds
+	return !modified.Truncate(time.Second).After(ims)
+}
+
+// etagMatches() reports whether an If-None-Match header lists etag. The
+// comparison is weak, as required for If-None-Match, so W/"x" matches "x";
+// compress() weakens the ETags of the responses it compresses.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestSnippetViewCaching(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	get := func(t *testing.T, header http.Header) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/view/1", nil)
+		assert.NilError(t, err)
+
+		// Uncompressed responses keep the strong ETag
+		req.Header.Set("Accept-Encoding", "identity")
+		for key, values := range header {
+			req.Header[key] = values
+		}
+
+		rs, err := ts.Client().Do(req)
+		assert.NilError(t, err)
+		rs.Body.Close()
+
+		return rs
+	}
+
+	// The first visit sets the CSRF cookie, which changes the ETag, so
+	// start from the second
+	get(t, nil)
+	rs := get(t, nil)
+
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("Cache-Control"), "private, no-cache")
+
+	etag := rs.Header.Get("ETag")
+	lastModified := rs.Header.Get("Last-Modified")
+	assert.Equal(t, etag != "", true)
+	assert.Equal(t, lastModified != "", true)
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		wantCode int
+	}{
+		{"Matching ETag", http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{"Weakened ETag", http.Header{"If-None-Match": {"W/" + etag}}, http.StatusNotModified},
+		{"One of several ETags", http.Header{"If-None-Match": {`"abc", ` + etag}}, http.StatusNotModified},
+		{"Stale ETag", http.Header{"If-None-Match": {`"abc"`}}, http.StatusOK},
+		{"Not modified since", http.Header{"If-Modified-Since": {lastModified}}, http.StatusNotModified},
+		{"Modified since", http.Header{"If-Modified-Since": {"Sat, 16 Mar 2024 10:15:00 GMT"}}, http.StatusOK},
+		{"ETag takes precedence", http.Header{"If-None-Match": {`"abc"`}, "If-Modified-Since": {lastModified}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs := get(t, tt.header)
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.Equal(t, rs.Header.Get("ETag"), etag)
+		})
+	}
+
+	t.Run("Logged in", func(t *testing.T) {
+		ts.login(t, "alice@example.com", "pa$$word")
+
+		rs := get(t, http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, rs.StatusCode, http.StatusOK)
+		assert.Equal(t, rs.Header.Get("Cache-Control"), "private, no-store")
+		assert.Equal(t, rs.Header.Get("ETag"), "")
+	})
+}
+
+func TestSnippetViewCachingCompressed(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	get := func(t *testing.T, ifNoneMatch string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/view/1", nil)
+		assert.NilError(t, err)
+
+		// Setting Accept-Encoding ourselves stops the transport from
+		// decompressing the body and dropping Content-Encoding
+		req.Header.Set("Accept-Encoding", "gzip")
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		rs, err := ts.Client().Do(req)
+		assert.NilError(t, err)
+		rs.Body.Close()
+
+		return rs
+	}
+
+	get(t, "")
+	rs := get(t, "")
+
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rs.Header.Get("Content-Encoding"), "gzip")
+
+	etag := rs.Header.Get("ETag")
+	assert.StringContains(t, etag, `W/"`)
+
+	// The 304 carries the same weak ETag as the compressed 200
+	rs = get(t, etag)
+	assert.Equal(t, rs.StatusCode, http.StatusNotModified)
+	assert.Equal(t, rs.Header.Get("ETag"), etag)
+}
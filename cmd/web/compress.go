@@ -0,0 +1,265 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// minCompressSize is the smallest response body worth compressing; below
+// this, the compressed body's framing outweighs what's saved.
+const minCompressSize = 1024
+
+// encodings are the content codings we can compress responses with, most
+// preferred first.
+var encodings = []string{"br", "gzip"}
+
+// encoder is implemented by both *brotli.Writer and *gzip.Writer.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoderPools keep compressors for reuse, by content coding, as each one
+// allocates a good deal of memory.
+var encoderPools = map[string]*sync.Pool{
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(io.Discard, 5)
+	}},
+	"gzip": {New: func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	}},
+}
+
+// negotiateEncoding() picks which of our encodings to use from a request's
+// Accept-Encoding header, preferring brotli when the client likes both as
+// much. It returns "" if the response should be sent uncompressed.
+func negotiateEncoding(acceptEncoding string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				q = 0
+			}
+		}
+		weights[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, ok := weights[encoding]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
+
+// compressibleTypes are the media types, besides text/*, +json and +xml,
+// which compress well. Images other than SVG are compressed already.
+var compressibleTypes = []string{
+	"application/javascript",
+	"application/json",
+	"application/xml",
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") ||
+		slices.Contains(compressibleTypes, mediaType)
+}
+
+// compress() compresses responses with brotli or gzip, whichever the client
+// prefers. Responses which are small, already encoded, partial, or of a type
+// which doesn't compress well are sent as they are.
+func compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+
+		// If the handler panics, whatever it buffered is dropped, leaving
+		// recoverPanic() free to send its error page. If the response has
+		// already started, the encoder still goes back to the pool, but
+		// without finishing the body, so it can't pass for a whole one.
+		defer cw.release()
+
+		next.ServeHTTP(cw, r)
+		cw.close()
+	})
+}
+
+// compressWriter holds back the status and the start of the body until
+// there's enough of the body to decide whether to compress it. Handlers
+// such as render() which write their whole body at once are decided on
+// their first write.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buf      []byte
+	started  bool
+	enc      encoder // Set once started, if the body is being compressed
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	// Informational responses, such as 103 Early Hints, go straight out
+	if status < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	if !cw.started {
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < minCompressSize {
+			return len(b), nil
+		}
+
+		err := cw.start()
+		if err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if cw.enc != nil {
+		return cw.enc.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// start() decides whether to compress the body, then sends the status and
+// whatever has been buffered.
+func (cw *compressWriter) start() error {
+	cw.started = true
+
+	h := cw.Header()
+
+	// Sniff the type now, as net/http would, since it can't once the body
+	// is compressed
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if len(cw.buf) >= minCompressSize &&
+		h.Get("Content-Encoding") == "" &&
+		h.Get("Content-Range") == "" &&
+		compressible(h.Get("Content-Type")) {
+
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+
+		// The compressed body is a different representation, so a strong
+		// validator no longer applies to it
+		if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		cw.enc = encoderPools[cw.encoding].Get().(encoder)
+		cw.enc.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if cw.enc != nil {
+		_, err := cw.enc.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// close() sends anything still buffered, and finishes the compressed body.
+func (cw *compressWriter) close() {
+	if !cw.started {
+		// Nothing written at all; let net/http send its default response
+		if cw.status == 0 {
+			return
+		}
+		cw.start()
+	}
+
+	if cw.enc != nil {
+		cw.enc.Close()
+	}
+	cw.release()
+}
+
+// release() returns the encoder, if any, to its pool. It's safe to call
+// more than once.
+func (cw *compressWriter) release() {
+	if cw.enc == nil {
+		return
+	}
+
+	cw.enc.Reset(io.Discard)
+	encoderPools[cw.encoding].Put(cw.enc)
+	cw.enc = nil
+}
+
+// Flush() sends what the handler has written so far, deciding whether to
+// compress on the strength of it.
+func (cw *compressWriter) Flush() {
+	if !cw.started {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.start()
+	}
+
+	if cw.enc != nil {
+		cw.enc.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.5, br;q=0", "gzip"},
+		{"GZIP", "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			assert.Equal(t, negotiateEncoding(tt.acceptEncoding), tt.want)
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	page := "<!doctype html><p>" + strings.Repeat("Hello, world! ", 200) + "</p>"
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		wantEncoding   string
+	}{
+		{"Brotli", "gzip, br", "", page, "br"},
+		{"Gzip", "gzip", "", page, "gzip"},
+		{"Not accepted", "", "", page, ""},
+		{"Small body", "gzip, br", "", "<p>Hello</p>", ""},
+		{"Incompressible type", "gzip, br", "image/png", page, ""},
+		{"JSON", "gzip, br", "application/json", page, "br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+
+			// Write the whole body at once after setting the status, as
+			// render() does
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(http.StatusTeapot)
+				w.Write([]byte(tt.body))
+			})
+
+			compress(next).ServeHTTP(rr, r)
+
+			rs := rr.Result()
+			assert.Equal(t, rs.StatusCode, http.StatusTeapot)
+			assert.Equal(t, rs.Header.Get("Vary"), "Accept-Encoding")
+			assert.Equal(t, rs.Header.Get("Content-Encoding"), tt.wantEncoding)
+
+			var body io.Reader = rs.Body
+			switch tt.wantEncoding {
+			case "br":
+				body = brotli.NewReader(rs.Body)
+			case "gzip":
+				body, err = gzip.NewReader(rs.Body)
+				assert.NilError(t, err)
+			}
+
+			got, err := io.ReadAll(body)
+			assert.NilError(t, err)
+			assert.Equal(t, string(got), tt.body)
+
+			// The type is sniffed before compressing, as it can't be after
+			if tt.wantEncoding != "" && tt.contentType == "" {
+				assert.Equal(t, rs.Header.Get("Content-Type"), "text/html; charset=utf-8")
+			}
+		})
+	}
+}
+
+func TestCompressPanic(t *testing.T) {
+	page := "<!doctype html><p>" + strings.Repeat("Hello, world! ", 200) + "</p>"
+
+	rr := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	// Enough is written to start the compressed body before the panic
+	var cw *compressWriter
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw = w.(*compressWriter)
+		w.Write([]byte(page))
+		panic("oops")
+	})
+
+	func() {
+		defer func() {
+			assert.Equal(t, recover(), any("oops"))
+		}()
+		compress(next).ServeHTTP(rr, r)
+	}()
+
+	// The encoder has gone back to the pool
+	assert.Equal(t, cw.enc == nil, true)
+
+	rs := rr.Result()
+	assert.Equal(t, rs.Header.Get("Content-Encoding"), "gzip")
+
+	// Without being finished, so the body doesn't pass for a whole one
+	gz, err := gzip.NewReader(rs.Body)
+	assert.NilError(t, err)
+	_, err = io.ReadAll(gz)
+	assert.Equal(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestCompressedStaticFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	original, err := fs.ReadFile(app.static.fsys, "css/main.css")
+	assert.NilError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/static/css/main.css", nil)
+	assert.NilError(t, err)
+	req.Header.Set("Accept-Encoding", "br")
+
+	rs, err := ts.Client().Do(req)
+	assert.NilError(t, err)
+	defer rs.Body.Close()
+
+	assert.Equal(t, rs.Header.Get("Content-Encoding"), "br")
+	assert.Equal(t, rs.Header.Get("Content-Type"), "text/css; charset=utf-8")
+	assert.Equal(t, strings.HasSuffix(rs.Header.Get("ETag"), `-br"`), true)
+
+	got, err := io.ReadAll(brotli.NewReader(rs.Body))
+	assert.NilError(t, err)
+	assert.Equal(t, bytes.Equal(got, original), true)
+}
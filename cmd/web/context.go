@@ -2,4 +2,11 @@ package main
 
 type contextKey string
 
-const isAuthenticatedContextKey = contextKey("isAuthenticated")
+const (
+	isAuthenticatedContextKey = contextKey("isAuthenticated")
+	userRolesContextKey       = contextKey("userRoles")
+	clientIPContextKey        = contextKey("clientIP")
+	requestSpanContextKey     = contextKey("requestSpan")
+	requestIDContextKey       = contextKey("requestID")
+	requestLogContextKey      = contextKey("requestLog")
+)
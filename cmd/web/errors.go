@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/justinas/nosurf"
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+// errorMessages explain the error statuses we send, and suggest what the
+// user could do next. Statuses without a message just show their name.
+var errorMessages = map[int]string{
+	http.StatusBadRequest:          "We couldn't make sense of that request. Please go back and try again.",
+	http.StatusForbidden:           "You don't have permission to see this page.",
+	http.StatusNotFound:            "We couldn't find that page. It may have been deleted, or the snippet may have expired.",
+	http.StatusMethodNotAllowed:    "That page doesn't accept this kind of request.",
+	http.StatusTooManyRequests:     "You've made a lot of requests in a short time. Please wait a moment before trying again.",
+	http.StatusInternalServerError: "Something went wrong on our side, and we've logged the problem. Please try again later.",
+}
+
+// errorData describes an error response, both to the error page's template
+// and as the JSON body sent to API clients.
+type errorData struct {
+	Status    int    `json:"status"`
+	Title     string `json:"error"`
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// isAPIRequest() reports whether the request is for the JSON API, whose
+// clients get errors as JSON rather than as HTML pages.
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// errorResponse() sends the user a page explaining the error, within the
+// site's usual layout. The page is rendered without touching the session,
+// as it can be sent by middleware before the session has been loaded. If
+// rendering fails the user gets a plain-text error instead.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int) {
+	e := errorData{
+		Status:    status,
+		Title:     http.StatusText(status),
+		Message:   errorMessages[status],
+		RequestID: getRequestID(r),
+	}
+
+	if isAPIRequest(r) {
+		app.writeJSON(w, r, status, e)
+		return
+	}
+
+	ts, ok := app.templateCache["error.tmpl.html"]
+	if ok {
+		data := templateData{
+			Year:            time.Now().Year(),
+			IsAuthenticated: app.isAuthenticated(r),
+			IsAdmin:         app.hasRole(r, models.RoleAdmin),
+			IsModerator:     app.hasRole(r, models.RoleModerator, models.RoleAdmin),
+			CSRFToken:       nosurf.Token(r),
+			Error:           e,
+		}
+
+		buf := new(bytes.Buffer)
+		err := ts.ExecuteTemplate(buf, "base", data)
+		if err == nil {
+			// The handler may already have set headers for the response it
+			// meant to send
+			w.Header().Del("Content-Length")
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(status)
+			buf.WriteTo(w)
+			return
+		}
+
+		app.logger.ErrorContext(r.Context(), "rendering error page: "+err.Error(), "request_id", e.RequestID, "status", status)
+	}
+
+	http.Error(w, e.Title, status)
+}
+
+// unmatched() wraps the mux so that requests matching none of its routes get
+// our error pages, rather than the mux's plain-text 404 and 405 responses.
+// The pages load the session, so the nav shows who's logged in.
+func (app *application) unmatched(mux *http.ServeMux) http.Handler {
+	errorPage := app.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Let the mux decide between 404 and 405, and which methods to
+		// list in the Allow header, but throw away its response
+		h, _ := mux.Handler(r)
+
+		rec := &discardWriter{header: http.Header{}}
+		h.ServeHTTP(rec, r)
+
+		if allow := rec.header.Get("Allow"); allow != "" {
+			w.Header().Set("Allow", allow)
+		}
+		app.clientError(w, r, rec.status)
+	}))
+
+	// These requests change nothing, so they needn't pass the CSRF check,
+	// but the nav's logout form still needs a token
+	csrfHandler := app.csrfHandler(errorPage)
+	csrfHandler.ExemptFunc(func(*http.Request) bool { return true })
+	errorPage = app.sessionManager.LoadAndSave(csrfHandler)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern == "" {
+			errorPage.ServeHTTP(w, r)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// discardWriter is a http.ResponseWriter which remembers the status and
+// headers written to it, and discards the body.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (dw *discardWriter) Header() http.Header {
+	return dw.header
+}
+
+func (dw *discardWriter) WriteHeader(status int) {
+	if dw.status == 0 {
+		dw.status = status
+	}
+}
+
+func (dw *discardWriter) Write(b []byte) (int, error) {
+	dw.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
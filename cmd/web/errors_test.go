@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestErrorPages(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		form      url.Values
+		wantCode  int
+		wantBody  string
+		wantAllow string
+	}{
+		{
+			name:     "Missing snippet",
+			urlPath:  "/snippet/view/2",
+			wantCode: http.StatusNotFound,
+			wantBody: "<h2>404 Not Found</h2>",
+		},
+		{
+			name:     "Unmatched route",
+			urlPath:  "/no/such/page",
+			wantCode: http.StatusNotFound,
+			wantBody: "<h2>404 Not Found</h2>",
+		},
+		{
+			name:      "Wrong method",
+			urlPath:   "/snippet/view/1",
+			form:      url.Values{},
+			wantCode:  http.StatusMethodNotAllowed,
+			wantBody:  "<h2>405 Method Not Allowed</h2>",
+			wantAllow: "GET, HEAD",
+		},
+		{
+			name:     "Failed CSRF check",
+			urlPath:  "/user/login",
+			form:     url.Values{},
+			wantCode: http.StatusBadRequest,
+			wantBody: "<h2>400 Bad Request</h2>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				code   int
+				header http.Header
+				body   string
+			)
+			if tt.form != nil {
+				code, header, body = ts.postForm(t, tt.urlPath, tt.form)
+			} else {
+				code, header, body = ts.get(t, tt.urlPath)
+			}
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Content-Type"), "text/html; charset=utf-8")
+			assert.Equal(t, header.Get("Allow"), tt.wantAllow)
+
+			// The error is shown within the usual layout, with the request's ID
+			assert.StringContains(t, body, tt.wantBody)
+			assert.StringContains(t, body, "<nav>")
+			assert.StringContains(t, body, "<code>"+header.Get("X-Request-ID")+"</code>")
+		})
+	}
+}
+
+func TestForbiddenPage(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/admin")
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, body, "<h2>403 Forbidden</h2>")
+
+	// The nav still knows who's logged in
+	assert.StringContains(t, body, "<button>Logout</button>")
+}
+
+func TestAPIErrors(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, header, body := ts.get(t, "/api/no/such/endpoint")
+	assert.Equal(t, code, http.StatusNotFound)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+	var got errorData
+	err := json.Unmarshal([]byte(body), &got)
+	assert.NilError(t, err)
+
+	assert.Equal(t, got.Status, http.StatusNotFound)
+	assert.Equal(t, got.Title, "Not Found")
+	assert.Equal(t, got.RequestID, header.Get("X-Request-ID"))
+}
@@ -1,17 +1,19 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/rhysmah/snippet-box/internal/models"
 	"github.com/rhysmah/snippet-box/internal/validator"
 )
 
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	snippets, err := app.snippets.Latest(r.Context())
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -26,27 +28,124 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil || id < 1 {
-		http.NotFound(w, r)
+		app.clientError(w, r, http.StatusNotFound)
 		return
 	}
 
 	// Use SnippetModel's Get() method to retrieve data for specific record based on ID.
-	snippet, err := app.snippets.Get(id)
+	snippet, err := app.snippets.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
-			http.NotFound(w, r)
+			app.clientError(w, r, http.StatusNotFound)
 		} else {
 			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	if app.cacheSnippet(w, r, snippet) {
+		return
+	}
+
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.Form = snippetReportForm{}
 
 	app.render(w, r, http.StatusOK, "view.tmpl.html", data)
 }
 
+type snippetReportForm struct {
+	Reason              string `form:"reason"`
+	Details             string `form:"details"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) snippetReportPost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	snippet, err := app.snippets.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, r, http.StatusNotFound)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var form snippetReportForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.PermittedValued(form.Reason, models.ReportReasons...), "reason", "Choose a reason for your report")
+	form.CheckField(validator.MaxChars(form.Details, 1000), "details", "Details cannot exceed 1000 characters")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "view.tmpl.html", data)
+		return
+	}
+
+	// Anyone can report a snippet, so limit how often they can do it
+	reporterID := app.authenticatedUserID(r)
+
+	count, err := app.reports.RecentCount(r.Context(), reporterID, app.clientIP(r), time.Hour)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if count >= maxReportsPerHour {
+		app.clientError(w, r, http.StatusTooManyRequests)
+		return
+	}
+
+	err = app.reports.Insert(r.Context(), id, reporterID, app.clientIP(r), form.Reason, form.Details)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Thanks, your report has been sent to our moderators.")
+
+	// Once enough people have independently reported a snippet, hide it
+	// until a moderator has had a chance to look at it
+	reporters, err := app.reports.OpenReporters(r.Context(), id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if reporters >= autoHideReporters {
+		err = app.snippets.SetHidden(r.Context(), id, true)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = app.reports.Decide(r.Context(), id, 0, models.DecisionAutoHide)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.audit(r, models.EventModeration, map[string]any{"snippet_id": id, "decision": models.DecisionAutoHide})
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+}
+
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 
@@ -61,6 +160,8 @@ type SnippetCreateForm struct {
 	Title               string `form:"title"`
 	Content             string `form:"content"`
 	Expires             int    `form:"expires"`
+	PublishAnyway       bool   `form:"publish_anyway"`
+	SecretsFound        bool   `form:"-"`
 	validator.Validator `form:"-"`
 }
 
@@ -69,7 +170,7 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -79,6 +180,16 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	form.CheckField(validator.NotBlank(form.Content), "content", "Content cannot be blank")
 	form.CheckField(validator.PermittedValued(form.Expires, 1, 7, 365), "expires", "Expiry must be 1, 7, or 365 days")
 
+	// Check the content doesn't contain any credentials, unless the user
+	// has seen the warning and chosen to publish anyway
+	if !form.PublishAnyway {
+		findings := app.secrets.Scan(form.Content)
+		if len(findings) > 0 {
+			form.SecretsFound = true
+			form.AddFieldErrors("content", describeFindings(findings))
+		}
+	}
+
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
@@ -86,12 +197,16 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	id, err := app.snippets.Insert(r.Context(), app.authenticatedUserID(r), form.Title, form.Content, form.Expires)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	app.metrics.snippetsCreated.Inc()
+
+	app.audit(r, models.EventSnippetCreate, map[string]any{"snippet_id": id, "secrets_override": form.PublishAnyway})
+
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
 	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
@@ -115,7 +230,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -136,7 +251,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 
 	// Attempt to create a new user in the database; if the email
 	// already exists, add error message to form and re-display it
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	err = app.users.Insert(r.Context(), form.Name, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldErrors("email", "Email address already in use")
@@ -150,6 +265,8 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	app.audit(r, models.EventSignup, map[string]any{"email": form.Email})
+
 	// Else, add flash message confirming user succesfully registered
 	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
 
@@ -175,7 +292,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -190,14 +307,26 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := app.users.Authenticate(form.Email, form.Password)
+	id, err := app.users.Authenticate(r.Context(), form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.audit(r, models.EventLoginFailed, map[string]any{"email": form.Email, "reason": "invalid credentials"})
+			app.metrics.loginFailures.WithLabelValues("invalid_credentials").Inc()
+
 			form.AddNonFieldError("Email or password is incorrect")
 
 			data := app.newTemplateData(r)
 			data.Form = form
 			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl.html", data)
+		} else if errors.Is(err, models.ErrAccountDisabled) {
+			app.audit(r, models.EventLoginFailed, map[string]any{"email": form.Email, "reason": "account disabled"})
+			app.metrics.loginFailures.WithLabelValues("account_disabled").Inc()
+
+			form.AddNonFieldError("This account has been disabled")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusForbidden, "login.tmpl.html", data)
 		} else {
 			app.serverError(w, r, err)
 		}
@@ -212,8 +341,21 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Record the new session so it shows up on the user's sessions page.
+	// This must happen before the session is logged in: a session missing
+	// from user_sessions couldn't be revoked.
+	token := app.sessionManager.Token(r.Context())
+	err = app.userSessions.Insert(r.Context(), id, token, app.clientIP(r), r.UserAgent(), app.sessionManager.Deadline(r.Context()))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// Add ID of current user to the session, so they're now logged in
 	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+	setLogUserID(r, id)
+
+	app.audit(r, models.EventLogin, nil)
 
 	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
 
@@ -221,14 +363,250 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 
+	// Forget the metadata for the session we're about to abandon
+	err := app.userSessions.Delete(r.Context(), app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// Renew session ID
-	err := app.sessionManager.RenewToken(r.Context())
+	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	app.audit(r, models.EventLogout, nil)
+
+	// Remove the user ID from the session, so they're now logged out
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+
 	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+func (app *application) accountSessions(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+
+	sessions, err := app.userSessions.All(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.UserSessions = sessions
+
+	// Flag the session making this request, so it can't be revoked from the list
+	token := app.sessionManager.Token(r.Context())
+	for _, s := range sessions {
+		if s.Token == token {
+			data.CurrentSessionID = s.ID
+		}
+	}
+
+	app.render(w, r, http.StatusOK, "sessions.tmpl.html", data)
+}
+
+type sessionRevokeForm struct {
+	ID int `form:"id"`
+}
+
+func (app *application) accountSessionRevokePost(w http.ResponseWriter, r *http.Request) {
+	var form sessionRevokeForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := app.userSessions.All(r.Context(), app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Only sessions belonging to the current user can be revoked; anything
+	// else (including the current session) is treated as not found.
+	current := app.sessionManager.Token(r.Context())
+	for _, s := range sessions {
+		if s.ID == form.ID && s.Token != current {
+			err = app.revokeSession(r.Context(), s.Token)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+
+			app.audit(r, models.EventSessionRevoke, map[string]any{"session_id": s.ID})
+
+			app.sessionManager.Put(r.Context(), "flash", "Session signed out.")
+			http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+			return
+		}
+	}
+
+	app.clientError(w, r, http.StatusNotFound)
+}
+
+func (app *application) accountSessionRevokeAllPost(w http.ResponseWriter, r *http.Request) {
+	err := app.revokeAllSessions(r.Context(), app.authenticatedUserID(r), app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.audit(r, models.EventSessionRevoke, map[string]any{"all": true})
+
+	app.sessionManager.Put(r.Context(), "flash", "All other sessions have been signed out.")
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+// accountExport is the JSON document users can download before deleting
+// their account; it holds everything we store that belongs to them.
+type accountExport struct {
+	Exported time.Time `json:"exported"`
+	Profile  struct {
+		ID      int       `json:"id"`
+		Name    string    `json:"name"`
+		Email   string    `json:"email"`
+		Created time.Time `json:"created"`
+	} `json:"profile"`
+	Snippets []snippetExport `json:"snippets"`
+}
+
+type snippetExport struct {
+	ID      int       `json:"id"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+func (app *application) accountExport(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	snippets, err := app.snippets.ForUser(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	export := accountExport{Exported: time.Now().UTC()}
+	export.Profile.ID = user.ID
+	export.Profile.Name = user.Name
+	export.Profile.Email = user.Email
+	export.Profile.Created = user.Created
+
+	// Always encode a list, never null, even if the user has no snippets
+	export.Snippets = []snippetExport{}
+	for _, s := range snippets {
+		export.Snippets = append(export.Snippets, snippetExport{
+			ID:      s.ID,
+			Title:   s.Title,
+			Content: s.Content,
+			Created: s.Created,
+			Expires: s.Expires,
+		})
+	}
+
+	js, err := json.MarshalIndent(export, "", "\t")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="snippetbox-export.json"`)
+	w.Write(js)
+}
+
+type accountDeleteForm struct {
+	Password            string `form:"password"`
+	Snippets            string `form:"snippets"`
+	validator.Validator `form:"-"`
+}
+
+func (app *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountDeleteForm{Snippets: "delete"}
+	app.render(w, r, http.StatusOK, "delete.tmpl.html", data)
+}
+
+func (app *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+	var form accountDeleteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "Password cannot be blank")
+	form.CheckField(validator.PermittedValued(form.Snippets, "delete", "keep"), "snippets", "Choose whether to delete or keep your snippets")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "delete.tmpl.html", data)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	// Make the user confirm who they are before doing anything irreversible
+	err = app.users.CheckPassword(r.Context(), userID, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldErrors("password", "Password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "delete.tmpl.html", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Deleting the account deletes or changes the user's snippets behind
+	// the snippet store's back
+	err = app.users.Delete(r.Context(), userID, form.Snippets == "keep")
+	app.purgeSnippetCache()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Record this while we still know who the user was
+	app.audit(r, models.EventAccountDelete, map[string]any{"kept_snippets": form.Snippets == "keep"})
+
+	// The sessions live outside the transaction that deleted the account,
+	// so they're revoked now it has committed. Any left behind by a failure
+	// here no longer authenticate anyone, as the user doesn't exist.
+	err = app.revokeAllSessions(r.Context(), userID, "")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Destroy our copy of the current session too, so it isn't written
+	// straight back at the end of the request.
+	err = app.sessionManager.Destroy(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your account has been deleted.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
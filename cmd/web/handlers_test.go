@@ -0,0 +1,359 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"github.com/rhysmah/snippet-box/internal/models/mocks"
+)
+
+func TestSnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid ID", "/snippet/view/1", http.StatusOK, mocks.MockSnippet.Content},
+		{"Non-existent ID", "/snippet/view/2", http.StatusNotFound, ""},
+		{"Negative ID", "/snippet/view/-1", http.StatusNotFound, ""},
+		{"Decimal ID", "/snippet/view/1.23", http.StatusNotFound, ""},
+		{"String ID", "/snippet/view/foo", http.StatusNotFound, ""},
+		{"Empty ID", "/snippet/view/", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserSignup(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, _, body := ts.get(t, "/user/signup")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	const (
+		validName     = "Erin"
+		validEmail    = "erin@example.com"
+		validPassword = "validPa$$word"
+		formTag       = "<form action='/user/signup' method='POST' novalidate>"
+	)
+
+	tests := []struct {
+		name         string
+		userName     string
+		userEmail    string
+		userPassword string
+		csrfToken    string
+		wantCode     int
+		wantFormTag  string
+	}{
+		{"Valid submission", validName, validEmail, validPassword, validCSRFToken, http.StatusSeeOther, ""},
+		{"Invalid CSRF token", validName, validEmail, validPassword, "wrongToken", http.StatusBadRequest, ""},
+		{"Empty name", "", validEmail, validPassword, validCSRFToken, http.StatusUnprocessableEntity, formTag},
+		{"Empty email", validName, "", validPassword, validCSRFToken, http.StatusUnprocessableEntity, formTag},
+		{"Empty password", validName, validEmail, "", validCSRFToken, http.StatusUnprocessableEntity, formTag},
+		{"Invalid email", validName, "erin@example.", validPassword, validCSRFToken, http.StatusUnprocessableEntity, formTag},
+		{"Short password", validName, validEmail, "pa$$", validCSRFToken, http.StatusUnprocessableEntity, formTag},
+		{"Duplicate email", validName, "alice@example.com", validPassword, validCSRFToken, http.StatusUnprocessableEntity, formTag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantFormTag != "" {
+				assert.StringContains(t, body, tt.wantFormTag)
+			}
+		})
+	}
+}
+
+func TestUserLogin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, _, body := ts.get(t, "/user/login")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		email        string
+		password     string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{"Valid credentials", "alice@example.com", mocks.MockPassword, http.StatusSeeOther, "/snippet/create", ""},
+		{"Wrong password", "alice@example.com", "wrongPa$$word", http.StatusUnprocessableEntity, "", "Email or password is incorrect"},
+		{"Unknown email", "nobody@example.com", mocks.MockPassword, http.StatusUnprocessableEntity, "", "Email or password is incorrect"},
+		{"Invalid email", "alice@", mocks.MockPassword, http.StatusUnprocessableEntity, "", "Must be a valid email address"},
+		{"Disabled account", "dave@example.com", mocks.MockPassword, http.StatusForbidden, "", "This account has been disabled"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, header, body := ts.postForm(t, "/user/login", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, header, _ := ts.get(t, "/snippet/create")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+	})
+
+	ts.login(t, "alice@example.com", mocks.MockPassword)
+
+	t.Run("Authenticated", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/create")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<form action='/snippet/create' method='POST'>")
+	})
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	t.Run("Invalid submission", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("title", "")
+		form.Add("content", "")
+		form.Add("expires", "30")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, body := ts.postForm(t, "/snippet/create", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Title cannot be blank")
+		assert.StringContains(t, body, "Content cannot be blank")
+		assert.StringContains(t, body, "Expiry must be 1, 7, or 365 days")
+	})
+
+	t.Run("Create and view", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("title", "O snail")
+		form.Add("content", "O snail\nClimb Mount Fuji,\nBut slowly, slowly!")
+		form.Add("expires", "7")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, header, _ := ts.postForm(t, "/snippet/create", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/snippet/view/2")
+
+		code, _, body := ts.get(t, "/snippet/view/2")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Snippet successfully created!")
+		assert.StringContains(t, body, "But slowly, slowly!")
+	})
+}
+
+func TestAccountDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "alice@example.com", mocks.MockPassword)
+
+	// Alice is also logged in somewhere else
+	other := newTestServer(t, app.routes())
+	other.login(t, "alice@example.com", mocks.MockPassword)
+
+	_, _, body := ts.get(t, "/account/delete")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	t.Run("Wrong password", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("password", "wrongPa$$word")
+		form.Add("snippets", "keep")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, body := ts.postForm(t, "/account/delete", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Password is incorrect")
+
+		exists, err := app.users.Exists(t.Context(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, true)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("password", mocks.MockPassword)
+		form.Add("snippets", "keep")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, header, _ := ts.postForm(t, "/account/delete", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/")
+
+		exists, err := app.users.Exists(t.Context(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, exists, false)
+
+		_, _, body := ts.get(t, "/")
+		assert.StringContains(t, body, "Your account has been deleted.")
+
+		// Logged out here and everywhere else
+		for _, s := range []*testServer{ts, other} {
+			code, header, _ := s.get(t, "/account/delete")
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), "/user/login")
+		}
+
+		sessions, err := app.userSessions.All(t.Context(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(sessions), 0)
+	})
+}
+
+func TestAccountSessionRevoke(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Alice is logged in twice, and Bob once
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "alice@example.com", mocks.MockPassword)
+	other := newTestServer(t, app.routes())
+	other.login(t, "alice@example.com", mocks.MockPassword)
+	bob := newTestServer(t, app.routes())
+	bob.login(t, "bob@example.com", mocks.MockPassword)
+
+	// Newest first, so Alice's current session is the older of her two
+	aliceSessions, err := app.userSessions.All(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(aliceSessions), 2)
+	otherID, currentID := aliceSessions[0].ID, aliceSessions[1].ID
+
+	bobSessions, err := app.userSessions.All(t.Context(), 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(bobSessions), 1)
+	bobID := bobSessions[0].ID
+
+	_, _, body := ts.get(t, "/account/sessions")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	revoke := func(t *testing.T, id int) int {
+		t.Helper()
+
+		form := url.Values{}
+		form.Add("id", strconv.Itoa(id))
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, _ := ts.postForm(t, "/account/sessions/revoke", form)
+		return code
+	}
+
+	t.Run("Another user's session", func(t *testing.T) {
+		assert.Equal(t, revoke(t, bobID), http.StatusNotFound)
+
+		code, _, _ := bob.get(t, "/account/sessions")
+		assert.Equal(t, code, http.StatusOK)
+	})
+
+	t.Run("Current session", func(t *testing.T) {
+		assert.Equal(t, revoke(t, currentID), http.StatusNotFound)
+	})
+
+	t.Run("Unknown session", func(t *testing.T) {
+		assert.Equal(t, revoke(t, 99), http.StatusNotFound)
+	})
+
+	t.Run("Own other session", func(t *testing.T) {
+		assert.Equal(t, revoke(t, otherID), http.StatusSeeOther)
+
+		code, header, _ := other.get(t, "/account/sessions")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/user/login")
+
+		code, _, _ = ts.get(t, "/account/sessions")
+		assert.Equal(t, code, http.StatusOK)
+	})
+}
+
+func TestAccountSessionRevokeAll(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	ts.login(t, "alice@example.com", mocks.MockPassword)
+
+	var others []*testServer
+	for range 2 {
+		other := newTestServer(t, app.routes())
+		other.login(t, "alice@example.com", mocks.MockPassword)
+		others = append(others, other)
+	}
+
+	bob := newTestServer(t, app.routes())
+	bob.login(t, "bob@example.com", mocks.MockPassword)
+
+	_, _, body := ts.get(t, "/account/sessions")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, _ := ts.postForm(t, "/account/sessions/revoke-all", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, header.Get("Location"), "/account/sessions")
+
+	// Only the session that asked is left
+	sessions, err := app.userSessions.All(t.Context(), 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 1)
+
+	code, _, body = ts.get(t, "/account/sessions")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "All other sessions have been signed out.")
+
+	for _, other := range others {
+		code, _, _ := other.get(t, "/account/sessions")
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	// Bob's sessions are his own business
+	code, _, _ = bob.get(t, "/account/sessions")
+	assert.Equal(t, code, http.StatusOK)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/rhysmah/snippet-box/internal/migrations"
+)
+
+// readyTimeout bounds how long /readyz waits on all its checks together.
+const readyTimeout = 2 * time.Second
+
+// probePaths are requested by load balancers, orchestrators and Prometheus
+// every few seconds, so logRequest() only logs them at debug level.
+var probePaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/version": true,
+	"/metrics": true,
+}
+
+// readinessCheck is something which must be working for the application
+// to serve traffic.
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// sessionStoreCheck() checks that the session store can be queried, by
+// looking up a token which never exists. The scs stores don't take a
+// context, so the lookup is abandoned (though it carries on in the
+// background) once ctx is done.
+func sessionStoreCheck(store scs.Store) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		result := make(chan error, 1)
+
+		go func() {
+			_, _, err := store.Find("readyz")
+			result <- err
+		}()
+
+		select {
+		case err := <-result:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// migrationsCheck() checks that every migration has been applied, so the
+// schema is the one this build expects.
+func migrationsCheck(migrator *migrations.Migrator) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(pending) > 0 {
+			return fmt.Errorf("pending migrations: %d, starting with %04d_%s",
+				len(pending), pending[0].Version, pending[0].Name)
+		}
+
+		return nil
+	}
+}
+
+// healthz reports that the process is up and serving requests. It doesn't
+// touch the database, so a database outage doesn't get the process
+// restarted.
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("OK"))
+}
+
+// readyz reports whether the application can serve traffic, running every
+// readiness check. The response lists each check as "ok" or "failed";
+// the reasons for failures are logged rather than shown to whoever asked.
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	results := map[string]string{}
+
+	for _, c := range app.readinessChecks {
+		err := c.check(ctx)
+		if err != nil {
+			app.logger.ErrorContext(ctx, "readiness check failed", "check", c.name, "error", err.Error())
+			results[c.name] = "failed"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		results[c.name] = "ok"
+	}
+
+	app.writeJSON(w, r, status, results)
+}
+
+// buildInfo describes the running binary.
+type buildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"` // When the revision was committed
+	Modified  bool   `json:"modified"`       // Built with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+// readBuildInfo() gets the module version and VCS details which the Go
+// toolchain records in the binary. Builds with `go run`, or from outside a
+// repository, have no VCS details.
+func readBuildInfo() buildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return buildInfo{Version: "unknown"}
+	}
+
+	b := buildInfo{
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Revision = s.Value
+		case "vcs.time":
+			b.Time = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+
+	return b
+}
+
+// version reports which build is running.
+func (app *application) version(w http.ResponseWriter, r *http.Request) {
+	app.writeJSON(w, r, http.StatusOK, readBuildInfo())
+}
+
+// writeJSON() sends data as a JSON response with the given status code.
+func (app *application) writeJSON(w http.ResponseWriter, r *http.Request, status int, data any) {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+	w.Write([]byte("\n"))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, header, body := ts.get(t, "/healthz")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, body, "OK")
+
+	// Probes don't get a session
+	assert.Equal(t, header.Get("Set-Cookie"), "")
+}
+
+func TestReadyz(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name     string
+		checks   []readinessCheck
+		wantCode int
+		wantBody map[string]string
+	}{
+		{
+			name:     "All passing",
+			checks:   []readinessCheck{{"database", ok}, {"migrations", ok}},
+			wantCode: http.StatusOK,
+			wantBody: map[string]string{"database": "ok", "migrations": "ok"},
+		},
+		{
+			name:     "One failing",
+			checks:   []readinessCheck{{"database", failing}, {"migrations", ok}},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: map[string]string{"database": "failed", "migrations": "ok"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.readinessChecks = tt.checks
+			ts := newTestServer(t, app.routes())
+
+			code, _, body := ts.get(t, "/readyz")
+			assert.Equal(t, code, tt.wantCode)
+
+			var got map[string]string
+			err := json.Unmarshal([]byte(body), &got)
+			assert.NilError(t, err)
+
+			assert.Equal(t, len(got), len(tt.wantBody))
+			for name, want := range tt.wantBody {
+				assert.Equal(t, got[name], want)
+			}
+
+			// The reason for a failure stays in the logs
+			assert.Equal(t, strings.Contains(body, "connection refused"), false)
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	code, header, body := ts.get(t, "/version")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, header.Get("Content-Type"), "application/json")
+
+	var got buildInfo
+	err := json.Unmarshal([]byte(body), &got)
+	assert.NilError(t, err)
+
+	assert.Equal(t, got.Version != "", true)
+	assert.Equal(t, got.GoVersion != "", true)
+}
@@ -2,27 +2,42 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-playground/form/v4"
 	"github.com/justinas/nosurf"
+	"github.com/rhysmah/snippet-box/internal/models"
+	"github.com/rhysmah/snippet-box/internal/secrets"
 )
 
 // serverError() helper writes log entry at Error level (including request method and URI as atts),
-// then sends generic 500 Internal Service Error response to the user.
+// then sends the user a generic 500 Internal Server Error page.
 func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
+	app.logServerError(r, err)
+	app.errorResponse(w, r, http.StatusInternalServerError)
+}
+
+// logServerError() writes the log entry for serverError(), for when it's too
+// late to send an error page.
+func (app *application) logServerError(r *http.Request, err error) {
 	var (
 		method = r.Method
 		uri    = r.URL.RequestURI()
 		trace  = string(debug.Stack())
 	)
 
-	app.logger.Error(err.Error(), "method", method, "uri", uri, "trace", trace)
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	app.logger.ErrorContext(r.Context(), err.Error(), "request_id", getRequestID(r), "method", method, "uri", uri, "trace", trace)
 }
 
 func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data templateData) {
@@ -41,7 +56,11 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 	// receives, else we send them an error.
 	buf := new(bytes.Buffer)
 
+	_, span := app.startSpan(r, "render "+page)
+	start := time.Now()
 	err := ts.ExecuteTemplate(buf, "base", data)
+	app.metrics.renderDuration.WithLabelValues(page).Observe(time.Since(start).Seconds())
+	span.End()
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -53,8 +72,10 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, status in
 	buf.WriteTo(w)
 }
 
-func (app *application) clientError(w http.ResponseWriter, status int) {
-	http.Error(w, http.StatusText(status), status)
+// clientError() sends the user an error page for a problem with their
+// request, e.g. 400 Bad Request or 404 Not Found.
+func (app *application) clientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.errorResponse(w, r, status)
 }
 
 func (app *application) newTemplateData(r *http.Request) templateData {
@@ -62,6 +83,9 @@ func (app *application) newTemplateData(r *http.Request) templateData {
 		Year:            time.Now().Year(),
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: app.isAuthenticated(r),
+		IsAdmin:         app.hasRole(r, models.RoleAdmin),
+		IsModerator:     app.hasRole(r, models.RoleModerator, models.RoleAdmin),
+		ReportReasons:   models.ReportReasons,
 		CSRFToken:       nosurf.Token(r),
 	}
 }
@@ -99,3 +123,167 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// hasRole() reports whether the current user holds any of the given roles.
+// Every authenticated user implicitly holds models.RoleUser.
+func (app *application) hasRole(r *http.Request, roles ...models.Role) bool {
+	if !app.isAuthenticated(r) {
+		return false
+	}
+
+	held, _ := r.Context().Value(userRolesContextKey).([]models.Role)
+
+	for _, role := range roles {
+		if role == models.RoleUser || slices.Contains(held, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticatedUserID() returns the ID of the logged-in user, or 0 if there
+// isn't one. Only use it behind authenticate, which has checked the user exists.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	return app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+}
+
+// revokeSession() destroys a session in the session store, along with the
+// metadata we hold about it.
+func (app *application) revokeSession(ctx context.Context, token string) error {
+	err := app.sessionManager.Store.Delete(token)
+	if err != nil {
+		return err
+	}
+
+	return app.userSessions.Delete(ctx, token)
+}
+
+// revokeAllSessions() signs the user out everywhere except for the session
+// identified by `except`; pass an empty string to revoke every session.
+func (app *application) revokeAllSessions(ctx context.Context, userID int, except string) error {
+	sessions, err := app.userSessions.All(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sessions {
+		if s.Token == except {
+			continue
+		}
+
+		err = app.revokeSession(ctx, s.Token)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// describeFindings() summarises suspected secrets as a single error message,
+// e.g. "Content looks like it contains secrets: AWS access key ID (line 3)".
+func describeFindings(findings []secrets.Finding) string {
+	descriptions := make([]string, len(findings))
+	for i, f := range findings {
+		descriptions[i] = fmt.Sprintf("%s (line %d)", f.Rule, f.Line)
+	}
+
+	return "Content looks like it contains secrets: " + strings.Join(descriptions, ", ")
+}
+
+// clientIP() returns the IP address of the client making the request, as
+// found by the realIP middleware.
+func (app *application) clientIP(r *http.Request) string {
+	ip, ok := r.Context().Value(clientIPContextKey).(string)
+	if !ok {
+		return app.ipResolver.ClientIP(r)
+	}
+	return ip
+}
+
+// getRequestID() returns the ID given to the request by requestID().
+func getRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// setLogUserID() records the ID of the user making the request, for the
+// access log written by logRequest().
+func setLogUserID(r *http.Request, userID int) {
+	if info, ok := r.Context().Value(requestLogContextKey).(*requestLog); ok {
+		info.userID = userID
+	}
+}
+
+// rateLimitByIP() and rateLimitByUser() identify clients for rateLimit().
+// IPv6 clients are identified by their /64, as that's usually the smallest
+// network given to a single customer, who could otherwise use a fresh
+// address for every request.
+func (app *application) rateLimitByIP(r *http.Request) string {
+	ip := app.clientIP(r)
+
+	addr, err := netip.ParseAddr(ip)
+	if err == nil && addr.Is6() && !addr.Is4In6() {
+		return "ip:" + netip.PrefixFrom(addr.WithZone(""), 64).Masked().String()
+	}
+	return "ip:" + ip
+}
+
+func (app *application) rateLimitByUser(r *http.Request) string {
+	return "user:" + strconv.Itoa(app.authenticatedUserID(r))
+}
+
+// maxAuditValueLength and maxAuditDetailsLength limit how much of the text
+// in an audit event's details is kept. Much of it, such as the email given
+// at login, comes straight from the client.
+const (
+	maxAuditValueLength   = 255
+	maxAuditDetailsLength = 4096
+)
+
+// audit() records a security-relevant event in the audit log, attributed to
+// the current user, if there is one. Failing to write to the audit log is
+// logged and counted, but doesn't stop the request that triggered it.
+func (app *application) audit(r *http.Request, eventType string, details map[string]any) {
+	event := models.AuditEvent{
+		Type:      eventType,
+		ActorID:   app.authenticatedUserID(r),
+		IP:        app.clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	if details != nil {
+		js, err := json.Marshal(capAuditDetails(details))
+		if err != nil {
+			app.metrics.auditWriteFailures.Inc()
+			app.logger.ErrorContext(r.Context(), err.Error(), "event", eventType)
+			return
+		}
+		event.Details = string(js)
+	}
+
+	err := app.auditLog.Insert(r.Context(), event)
+	if err != nil {
+		app.metrics.auditWriteFailures.Inc()
+		app.logger.ErrorContext(r.Context(), "writing audit log: "+err.Error(), "event", eventType)
+	}
+}
+
+// capAuditDetails() returns a copy of details with long strings cut short.
+// If the details would still be too long, they're replaced by the start of
+// their JSON encoding.
+func capAuditDetails(details map[string]any) map[string]any {
+	capped := make(map[string]any, len(details))
+	for key, value := range details {
+		if s, ok := value.(string); ok && utf8.RuneCountInString(s) > maxAuditValueLength {
+			value = string([]rune(s)[:maxAuditValueLength]) + "…"
+		}
+		capped[key] = value
+	}
+
+	js, err := json.Marshal(capped)
+	if err == nil && len(js) > maxAuditDetailsLength {
+		return map[string]any{"truncated": strings.ToValidUTF8(string(js[:maxAuditDetailsLength]), "")}
+	}
+	return capped
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+// failingAuditModel is an audit log which can't be written to.
+type failingAuditModel struct {
+	models.AuditStore
+}
+
+func (failingAuditModel) Insert(context.Context, models.AuditEvent) error {
+	return errors.New("audit log unavailable")
+}
+
+func TestAuditWriteFailures(t *testing.T) {
+	app := newTestApplication(t)
+	app.auditLog = failingAuditModel{}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	ctx, err := app.sessionManager.Load(r.Context(), "")
+	assert.NilError(t, err)
+	r = r.WithContext(ctx)
+
+	app.audit(r, models.EventLogin, nil)
+	app.audit(r, models.EventLogin, map[string]any{"email": "alice@example.com"})
+
+	assert.Equal(t, testutil.ToFloat64(app.metrics.auditWriteFailures), 2.0)
+}
+
+func TestCapAuditDetails(t *testing.T) {
+	tests := []struct {
+		name    string
+		details map[string]any
+		want    string
+	}{
+		{
+			name:    "Short",
+			details: map[string]any{"email": "alice@example.com", "id": 1},
+			want:    `{"email":"alice@example.com","id":1}`,
+		},
+		{
+			name:    "Long value",
+			details: map[string]any{"email": strings.Repeat("é", 300)},
+			want:    `{"email":"` + strings.Repeat("é", maxAuditValueLength) + `…"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			js, err := json.Marshal(capAuditDetails(tt.details))
+			assert.NilError(t, err)
+			assert.Equal(t, string(js), tt.want)
+		})
+	}
+
+	t.Run("Too many values", func(t *testing.T) {
+		details := map[string]any{}
+		for i := range 100 {
+			details[strings.Repeat("k", i+1)] = strings.Repeat("v", maxAuditValueLength)
+		}
+
+		js, err := json.Marshal(capAuditDetails(details))
+		assert.NilError(t, err)
+		assert.Equal(t, len(js) <= maxAuditDetailsLength+100, true)
+		assert.StringContains(t, string(js), `{"truncated":"{`)
+	})
+}
@@ -1,59 +1,152 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
 	"html/template"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/rhysmah/snippet-box/internal/config"
+	"github.com/rhysmah/snippet-box/internal/migrations"
 	"github.com/rhysmah/snippet-box/internal/models"
+	"github.com/rhysmah/snippet-box/internal/ratelimit"
+	"github.com/rhysmah/snippet-box/internal/realip"
+	"github.com/rhysmah/snippet-box/internal/secrets"
+	"github.com/rhysmah/snippet-box/internal/tracing"
 
 	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/postgresstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 
-	// We're not using anything from this import, so we prefix it with an
+	// We're not using anything from these imports, so we prefix them with an
 	// underscore, else we'll get a compile-time error. We need the `init`
-	// function to run from this package so it can register itself with the
-	// database/sql package.
+	// functions to run from these packages so they can register themselves
+	// with the database/sql package.
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
 // Define an application struct to hold the application-wide dependencies for the
 // web application. For now, include only the structured logger; more to be added.
 // Add the SnippetModel from the `internal/models` directory; like the logger,
-// we've injected this as a dependency in our application.
+// we've injected this as a dependency in our application. The models are
+// held as interfaces, so tests can inject the in-memory versions from
+// `internal/models/mocks` instead.
 type application struct {
-	logger         *slog.Logger
-	snippets       *models.SnippetModel
-	users          *models.UserModel
-	templateCache  map[string]*template.Template
-	formDecoder    *form.Decoder
-	sessionManager *scs.SessionManager
+	logger          *slog.Logger
+	snippets        models.SnippetStore
+	users           models.UserStore
+	userSessions    models.UserSessionStore
+	reports         models.ReportStore
+	auditLog        models.AuditStore
+	secrets         *secrets.Scanner
+	static          *staticFiles
+	templateCache   map[string]*template.Template
+	formDecoder     *form.Decoder
+	sessionManager  *scs.SessionManager
+	rateLimiter     ratelimit.Store
+	limits          routeLimits
+	ipResolver      *realip.Resolver
+	readinessChecks []readinessCheck
+	metrics         *metrics
+	metricsAddr     string // If set, /metrics is served here, not on the main server
+	tracer          trace.Tracer
 }
 
-// TODO (if applicable): create a `config` struct for configuration settings
+// routeLimits holds the rate limits applied to each group of routes.
+type routeLimits struct {
+	dynamic   ratelimit.Limit // Per client IP address
+	protected ratelimit.Limit // Per user
+}
 
 func main() {
-	addr := flag.String("addr", ":4000", "HTTP network address")
-	dsn := flag.String("dsn", "web:1234@/snippetbox?parseTime=true", "MySQL data source name")
-	flag.Parse()
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	logger := newLogger(os.Stdout, cfg.Log)
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	// `web config print` shows the settings in effect, then exits
+	if len(args) > 0 && args[0] == "config" {
+		if len(args) != 2 || args[1] != "print" {
+			logger.Error("usage: web [flags] config print")
+			os.Exit(1)
+		}
+
+		err = cfg.Print(os.Stdout)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Database
-	db, err := openDB(*dsn)
+	dialect := models.Dialect(cfg.DB.Driver)
+
+	db, err := openDB(dialect, cfg.DB.DSN)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
 	defer db.Close()
 
-	templateCache, err := newTemplateCache()
+	// `web migrate ...` manages the schema instead of starting the server
+	if len(args) > 0 && args[0] == "migrate" {
+		err = runMigrate(os.Stdout, db, args[1:])
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Tracing is off, and spans go nowhere, unless a collector is configured
+	var tracerProvider *sdktrace.TracerProvider
+	if cfg.Tracing.Endpoint != "" {
+		tracerProvider, err = tracing.New(context.Background(), tracing.Options{
+			Endpoint:    cfg.Tracing.Endpoint,
+			SampleRatio: cfg.Tracing.SampleRatio,
+			Version:     readBuildInfo().Version,
+		})
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+
+		otel.SetTracerProvider(tracerProvider)
+		logger.Info("tracing enabled", slog.String("endpoint", cfg.Tracing.Endpoint))
+	}
+
+	static, err := newStaticFiles()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	templateCache, err := newTemplateCache(static)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
@@ -61,24 +154,77 @@ func main() {
 
 	formDecoder := form.NewDecoder()
 
+	// Already checked by config.Load
+	trusted, _ := realip.ParseTrusted(cfg.Security.TrustedProxies)
+
+	var rateLimiter ratelimit.Store
+	if cfg.RateLimit.Store == "mysql" {
+		rateLimiter = ratelimit.NewMySQLStore(db.DB, 10*time.Minute)
+	} else {
+		rateLimiter = ratelimit.NewMemoryStore(10 * time.Minute)
+	}
+
+	secretScanner, err := newSecretScanner(cfg.Security.SecretRules)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	// Initialize new session manager; configure it to
-	// use MySQL database as the session store. Set a
-	// lifetime of 12 hours, meaning sessions will expire
-	// 12 hours after the sessions are created and stored.
+	// use the database as the session store. Sessions
+	// expire once their lifetime (12 hours by default)
+	// has passed since they were created.
+	metrics := newMetrics(db.DB)
+
+	sessionStore := newSessionStore(db)
+
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Store = &instrumentedStore{Store: sessionStore, metrics: metrics}
+	sessionManager.Lifetime = cfg.Session.Lifetime
 
 	// Cookies will ONLY be sent when using HTTPS, not HTTP
 	sessionManager.Cookie.Secure = true
 
+	migrator, err := migrations.New(db.DB, string(db.Dialect))
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	var snippets models.SnippetStore = &models.SnippetModel{DB: db}
+	if cfg.Cache.Enabled {
+		snippets = newCachedSnippetStore(snippets, cfg.Cache.Size, cfg.Cache.TTL, metrics)
+	}
+
 	app := &application{
 		logger:         logger,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
+		snippets:       snippets,
+		users:          &models.UserModel{DB: db, BcryptCost: cfg.Security.BcryptCost},
+		userSessions:   &models.UserSessionModel{DB: db},
+		reports:        &models.ReportModel{DB: db},
+		auditLog:       &models.AuditModel{DB: db},
+		secrets:        secretScanner,
+		rateLimiter:    rateLimiter,
+		limits:         routeLimits{dynamic: cfg.RateLimit.Dynamic, protected: cfg.RateLimit.Protected},
+		ipResolver:     &realip.Resolver{Trusted: trusted, Header: cfg.Security.ProxyHeader},
+		static:         static,
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		metrics:        metrics,
+		tracer:         otel.Tracer("github.com/rhysmah/snippet-box/cmd/web"),
+		metricsAddr:    cfg.Metrics.Addr,
+		readinessChecks: []readinessCheck{
+			{"database", db.PingContext},
+			{"sessions", sessionStoreCheck(sessionStore)},
+			{"migrations", migrationsCheck(migrator)},
+		},
+	}
+
+	err = app.bootstrapAdmin(context.Background(), cfg.Admin)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
 	}
 
 	tlsConfig := &tls.Config{
@@ -86,38 +232,123 @@ func main() {
 	}
 
 	server := &http.Server{
-		Addr:         *addr,
+		Addr:         cfg.Addr,
 		Handler:      app.routes(),
 		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
 		TLSConfig:    tlsConfig,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
 	}
 
-	// Because `addr` is a pointer to a string, we must
-	// dereference it to get access to its value.
 	// The first argument is the message; the variadic variables that
 	// follow are key-value pairs.
 	// Using slog.String() is optional, but it adds a level of type-safety
 	// and prevents, for example, leaving out a key or value.
-	logger.Info("starting server", slog.String("addr", *addr))
+	logger.Info("starting server", slog.String("addr", cfg.Addr))
+
+	// Deploys stop the process with SIGTERM (or SIGINT, from the terminal);
+	// either one lets in-flight requests finish before we exit. If the
+	// metrics server fails, the main server is shut down too.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var metricsErr error
+	var wg sync.WaitGroup
+
+	if cfg.Metrics.Addr != "" {
+		metricsServer := &http.Server{
+			Addr:         cfg.Metrics.Addr,
+			Handler:      app.metricsRoutes(),
+			ErrorLog:     server.ErrorLog,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+		}
+
+		logger.Info("starting metrics server", slog.String("addr", cfg.Metrics.Addr))
+
+		wg.Go(func() {
+			metricsErr = app.serve(ctx, metricsServer, metricsServer.ListenAndServe, cfg.Server.ShutdownTimeout)
+			cancel()
+		})
+	}
 
 	// Pass location of certificate and private key
-	err = server.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
+	err = app.serve(ctx, server, func() error {
+		return server.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	}, cfg.Server.ShutdownTimeout)
+
+	cancel()
+	wg.Wait()
+
+	err = errors.Join(err, metricsErr)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+
+	// Unless a server timed out shutting down, no requests are running now,
+	// so nothing else is using the stores or the database. If one did time
+	// out, the requests it abandoned may still be running, and will fail
+	// once the database is closed. Session data is saved as each request
+	// finishes, so there are no pending writes left to flush.
+	logger.Info("stopping background workers")
+	stopBackgroundWorkers(rateLimiter, sessionStore)
+
+	// Send any spans still waiting to be exported
+	if tracerProvider != nil {
+		logger.Info("flushing traces")
+
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		flushErr := tracerProvider.Shutdown(flushCtx)
+		cancelFlush()
+		if flushErr != nil {
+			logger.Error(flushErr.Error())
+		}
+	}
+
+	logger.Info("closing database")
+	closeErr := db.Close()
+	if closeErr != nil {
+		logger.Error(closeErr.Error())
+	}
+
+	if err != nil || closeErr != nil {
+		os.Exit(1)
+	}
+
+	logger.Info("shutdown complete")
+}
+
+// newLogger() returns a logger writing in the configured format, at the
+// configured level and above. Records logged with a request's context are
+// tagged with its trace ID.
+func newLogger(w io.Writer, cfg config.LogConfig) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+
+	var handler slog.Handler = slog.NewTextHandler(w, opts)
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(w, opts)
+	}
 
-	logger.Error(err.Error())
-	os.Exit(1)
+	return slog.New(tracing.LogHandler{Handler: handler})
 }
 
-func openDB(dsn string) (*sql.DB, error) {
+func openDB(dialect models.Dialect, dsn string) (*models.DB, error) {
+
+	if dialect == models.SQLite {
+		dsn = sqliteDSN(dsn)
+	}
 
 	// This does NOT open any connections; it simply initializes
 	// a pool of connections for future use. These connections are
 	// Go manages this pool of connections automatically, opening
 	// and closing connections to the database via the driver.
 	// This pool of connections is safe for concurrent use.
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open(dialect.DriverName(), dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -131,5 +362,73 @@ func openDB(dsn string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	return db, nil
+	return &models.DB{DB: db, Dialect: dialect}, nil
+}
+
+// sqliteDSN() adds the connection options we rely on to a SQLite DSN,
+// unless they've been set explicitly. By default the driver writes times in
+// Go's own format, which SQLite's date functions (and so the session store)
+// can't read. SQLite also allows only one writer at a time, so a busy
+// timeout makes concurrent writes wait their turn instead of failing.
+func sqliteDSN(dsn string) string {
+	options := []struct{ key, value string }{
+		{"_time_format=", "_time_format=sqlite"},
+		{"_pragma=busy_timeout", "_pragma=busy_timeout(5000)"},
+	}
+
+	for _, o := range options {
+		if strings.Contains(dsn, o.key) {
+			continue
+		}
+		if strings.Contains(dsn, "?") {
+			dsn += "&" + o.value
+		} else {
+			dsn += "?" + o.value
+		}
+	}
+
+	return dsn
+}
+
+// newSessionStore() returns the scs session store which matches the
+// database's dialect. Each expects a `sessions` table in its own format.
+func newSessionStore(db *models.DB) scs.Store {
+	switch db.Dialect {
+	case models.SQLite:
+		return sqlite3store.New(db.DB)
+	case models.Postgres:
+		return postgresstore.New(db.DB)
+	}
+	return mysqlstore.New(db.DB)
+}
+
+// newSecretScanner() sets up the detectors used to look for secrets in new
+// snippets. Rules read from rulesPath, if it's set, are used alongside the
+// built-in ones.
+func newSecretScanner(rulesPath string) (*secrets.Scanner, error) {
+	var rules []secrets.Rule
+	rules = append(rules, secrets.DefaultRules...)
+
+	if rulesPath != "" {
+		extra, err := secrets.LoadRules(rulesPath)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, extra...)
+	}
+
+	regexDetector, err := secrets.NewRegexDetector(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := &secrets.Scanner{
+		Detectors: []secrets.Detector{
+			regexDetector,
+			secrets.PEMDetector{},
+			secrets.DefaultEntropyDetector,
+		},
+	}
+
+	return scanner, nil
 }
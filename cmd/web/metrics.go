@@ -0,0 +1,228 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// metricsNamespace prefixes the names of all our own metrics.
+const metricsNamespace = "snippetbox"
+
+// metrics holds the application's Prometheus metrics. They're registered
+// with their own registry rather than the global one, so that every
+// application (and every test) gets a fresh set.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests           *prometheus.CounterVec
+	requestDuration    *prometheus.HistogramVec
+	sessionOps         *prometheus.CounterVec
+	sessionDuration    *prometheus.HistogramVec
+	renderDuration     *prometheus.HistogramVec
+	snippetsCreated    prometheus.Counter
+	loginFailures      *prometheus.CounterVec
+	auditWriteFailures prometheus.Counter
+	cacheLookups       *prometheus.CounterVec
+}
+
+// newMetrics() creates and registers every metric, along with the Go
+// runtime and process collectors. If db isn't nil, the connection pool's
+// statistics are collected too.
+func newMetrics(db *sql.DB) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by route pattern, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+
+		sessionOps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "session_store_operations_total",
+			Help:      "Session store operations, by operation and result (ok or error).",
+		}, []string{"operation", "result"}),
+
+		sessionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "session_store_operation_duration_seconds",
+			Help:      "Time taken by session store operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "template_render_duration_seconds",
+			Help:      "Time taken to render each page template.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1},
+		}, []string{"page"}),
+
+		snippetsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "snippets_created_total",
+			Help:      "Snippets created.",
+		}),
+
+		loginFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "login_failures_total",
+			Help:      "Failed login attempts, by reason.",
+		}, []string{"reason"}),
+
+		auditWriteFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "audit_write_failures_total",
+			Help:      "Audit events which couldn't be written to the audit log.",
+		}),
+
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_lookups_total",
+			Help:      "Lookups in the snippet cache, by what was looked up (snippet or latest) and result (hit or miss).",
+		}, []string{"cache", "result"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.sessionOps,
+		m.sessionDuration,
+		m.renderDuration,
+		m.snippetsCreated,
+		m.loginFailures,
+		m.auditWriteFailures,
+		m.cacheLookups,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, metricsNamespace))
+	}
+
+	return m
+}
+
+// handler() serves the metrics in the Prometheus text format.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// knownMethods are the HTTP methods which get their own label value. Any
+// other method is counted as OTHER, so that clients can't create an
+// unbounded number of time series.
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// instrument() records the count and duration of requests, labelled with
+// the pattern of the route which handled them, and names the request's span
+// after the route too. It must wrap the ServeMux
+// directly: the mux sets r.Pattern on the request it's given, so no
+// middleware in between may replace the request.
+func (app *application) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+
+		// Deferred so that requests which panic are counted too. They
+		// haven't been sent recoverPanic()'s response yet, so they're counted
+		// as the 500 they'll become, then the panic carries on up to it.
+		defer func() {
+			p := recover()
+
+			status := rec.status
+			if p != nil {
+				status = http.StatusInternalServerError
+			} else if status == 0 {
+				status = http.StatusOK
+			}
+
+			// Requests which matched no route are lumped together, rather
+			// than labelled with their (unbounded) paths
+			route := r.Pattern
+			if route == "" {
+				route = "unmatched"
+			}
+
+			method := r.Method
+			if !knownMethods[method] {
+				method = "OTHER"
+			}
+
+			labels := prometheus.Labels{"route": route, "method": method, "status": strconv.Itoa(status)}
+			app.metrics.requests.With(labels).Inc()
+			app.metrics.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+
+			if span, ok := r.Context().Value(requestSpanContextKey).(trace.Span); ok {
+				nameRequestSpan(span, r.Pattern, status)
+			}
+
+			if p != nil {
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// instrumentedStore wraps a session store to count and time its
+// operations.
+type instrumentedStore struct {
+	scs.Store
+	metrics *metrics
+}
+
+func (s *instrumentedStore) observe(operation string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	s.metrics.sessionOps.WithLabelValues(operation, result).Inc()
+	s.metrics.sessionDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func (s *instrumentedStore) Find(token string) ([]byte, bool, error) {
+	start := time.Now()
+	b, found, err := s.Store.Find(token)
+	s.observe("find", start, err)
+	return b, found, err
+}
+
+func (s *instrumentedStore) Commit(token string, b []byte, expiry time.Time) error {
+	start := time.Now()
+	err := s.Store.Commit(token, b, expiry)
+	s.observe("commit", start, err)
+	return err
+}
+
+func (s *instrumentedStore) Delete(token string) error {
+	start := time.Now()
+	err := s.Store.Delete(token)
+	s.observe("delete", start, err)
+	return err
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.get(t, "/snippet/view/1")
+	ts.get(t, "/snippet/view/1")
+	ts.get(t, "/snippet/view/2")
+	ts.get(t, "/no/such/page")
+
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "wrongPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	ts.login(t, "alice@example.com", "pa$$word")
+
+	code, _, body := ts.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusOK)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Requests by route", `snippetbox_http_requests_total{method="GET",route="GET /snippet/view/{id}",status="200"} 2`},
+		{"Requests by status", `snippetbox_http_requests_total{method="GET",route="GET /snippet/view/{id}",status="404"} 1`},
+		{"Unmatched routes", `snippetbox_http_requests_total{method="GET",route="unmatched",status="404"} 1`},
+		{"Latency", `snippetbox_http_request_duration_seconds_count{method="GET",route="GET /snippet/view/{id}",status="200"} 2`},
+		{"Template rendering", `snippetbox_template_render_duration_seconds_count{page="view.tmpl.html"} 2`},
+		{"Session store", `snippetbox_session_store_operations_total{operation="commit",result="ok"}`},
+		{"Login failures", `snippetbox_login_failures_total{reason="invalid_credentials"} 1`},
+		{"Go runtime", `go_goroutines`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.StringContains(t, body, tt.want)
+		})
+	}
+}
+
+func TestMetricsOnSeparateAddress(t *testing.T) {
+	app := newTestApplication(t)
+	app.metricsAddr = "localhost:4001"
+
+	ts := newTestServer(t, app.routes())
+	code, _, _ := ts.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	ts = newTestServer(t, app.metricsRoutes())
+	code, _, _ = ts.get(t, "/metrics")
+	assert.Equal(t, code, http.StatusOK)
+}
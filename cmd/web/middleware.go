@@ -2,10 +2,19 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/justinas/nosurf"
+	"github.com/rhysmah/snippet-box/internal/models"
+	"github.com/rhysmah/snippet-box/internal/ratelimit"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func commonHeaders(next http.Handler) http.Handler {
@@ -25,38 +34,157 @@ func commonHeaders(next http.Handler) http.Handler {
 	})
 }
 
-// As a method on `application`, this middleware has access to handler
-// dependencies, including the logger function
+// realIP() works out the client's IP address, looking through any trusted
+// reverse proxies, and stores it in the request context for app.clientIP().
+// It must come before anything that needs the client's IP.
+func (app *application) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPContextKey, app.ipResolver.ClientIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestIDHeader carries a request's ID, both from a proxy in front of us
+// and back out in the response.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDRX matches the request IDs we accept from clients. Anything
+// else is replaced, so clients can't inject text into the logs.
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// requestID() gives every request an ID, taken from the X-Request-ID header
+// if it's set (and sensible) or else generated, to tie together the log
+// lines written about it. The ID is stored in the request context for
+// getRequestID() and echoed in the response headers.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDRX.MatchString(id) {
+			id = rand.Text()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// responseRecorder wraps a ResponseWriter to remember the status code and
+// the number of bytes sent.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	// Informational responses, such as 103 Early Hints, come before the
+	// real status
+	if rec.status == 0 && status >= http.StatusOK {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// requestLog collects details for the access log which are only known
+// further down the chain, such as who the user is. logRequest() puts one in
+// the request context; see setLogUserID().
+type requestLog struct {
+	userID int
+}
+
+// logRequest() writes one access log line per request, once it's been
+// handled, with the response's status and size and how long it took.
+// It must come after realIP() and requestID(), and before recoverPanic(),
+// so that requests which panic are logged with the 500 they get.
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var (
-			ip     = r.RemoteAddr
-			proto  = r.Proto
-			method = r.Method
-			uri    = r.URL.RequestURI()
-		)
+		r, span := app.startSpan(r, "logRequest")
+		defer span.End()
 
-		app.logger.Info("received request", "ip", ip, "proto", proto, "method", method, "uri", uri)
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		info := &requestLog{}
 
-		next.ServeHTTP(w, r)
+		// Log from a defer, so that responses recoverPanic() aborts are
+		// logged too
+		defer func() {
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			// Probes arrive constantly, and would drown out everything else
+			level := slog.LevelInfo
+			if probePaths[r.URL.Path] {
+				level = slog.LevelDebug
+			}
+
+			app.logger.LogAttrs(r.Context(), level, "request",
+				slog.String("request_id", getRequestID(r)),
+				slog.String("ip", app.clientIP(r)),
+				slog.String("proto", r.Proto),
+				slog.String("method", r.Method),
+				slog.String("uri", r.URL.RequestURI()),
+				slog.Int("status", status),
+				slog.Int("bytes", rec.bytes),
+				slog.Duration("duration", time.Since(start)),
+				slog.Int("user_id", info.userID),
+			)
+		}()
+
+		ctx := context.WithValue(r.Context(), requestLogContextKey, info)
+		next.ServeHTTP(rec, r.WithContext(ctx))
 	})
 }
 
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, span := app.startSpan(r, "recoverPanic")
+		defer span.End()
+
+		rec := &responseRecorder{ResponseWriter: w}
 
 		// Deferred function will ALWAYS run in the event of a panic
 		// as Go unwinds the stack
 		defer func() {
 
 			if err := recover(); err != nil {
+				err := fmt.Errorf("%s", err)
+				span.RecordError(err)
+				span.SetStatus(codes.Error, "panic")
+
+				// Once the status has been sent, an error page would only be
+				// tacked onto the end of whatever went before it. Abort the
+				// response instead, so the client sees it fail.
+				if rec.status != 0 {
+					app.logServerError(r, err)
+					panic(http.ErrAbortHandler)
+				}
+
 				// If a panic, close the connection so no more requests can be made
 				w.Header().Set("Connection", "close")
-				app.serverError(w, r, fmt.Errorf("%s", err))
+
+				app.serverError(w, r, err)
 			}
 		}()
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(rec, r)
 	})
 }
 
@@ -77,8 +205,26 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireRole() only lets through users holding at least one of the given
+// roles; everyone else gets a 403. It expects to run after requireAuthentication,
+// e.g. protected.Append(app.requireRole(models.RoleAdmin)).
+func (app *application) requireRole(roles ...models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.hasRole(r, roles...) {
+				app.clientError(w, r, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, span := app.startSpan(r, "authenticate")
+		defer span.End()
 
 		// Retrieve authenticatedUserID value from session
 		// If the int zero value (0) is returned, then the user does not exist.
@@ -90,7 +236,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		// Check if user exists in the database (i.e., non-zero user ID is returned)
-		exists, err := app.users.Exists(id)
+		exists, err := app.users.Exists(r.Context(), id)
 		if err != nil {
 			app.serverError(w, r, err)
 			return
@@ -99,16 +245,41 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// If a matching user IS found, request is coming from authenticated user
 		// Create a copy of request with the isAuthenticatedContextKey set to true
 		if exists {
+			roles, err := app.users.Roles(r.Context(), id)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+
+			setLogUserID(r, id)
+
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, userRolesContextKey, roles)
 			r = r.WithContext(ctx)
+
+			// Keep the "last seen" time on the user's sessions page current
+			err = app.userSessions.Touch(r.Context(), app.sessionManager.Token(r.Context()))
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func noSurf(next http.Handler) http.Handler {
+func (app *application) noSurf(next http.Handler) http.Handler {
+	return app.csrfHandler(next)
+}
+
+// csrfHandler() returns the CSRF protection applied by noSurf(), for callers
+// which need to configure it further.
+func (app *application) csrfHandler(next http.Handler) *nosurf.CSRFHandler {
 	csrfHandler := nosurf.New(next)
+	csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.clientError(w, r, http.StatusBadRequest)
+	}))
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
@@ -117,3 +288,31 @@ func noSurf(next http.Handler) http.Handler {
 
 	return csrfHandler
 }
+
+// rateLimit() limits how often the client identified by key() can make
+// requests to a group of routes; requests over the limit get a 429. Each
+// group has its own buckets, so the same client can be limited separately
+// by each group it passes through.
+func (app *application) rateLimit(group string, limit ratelimit.Limit, key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit.Off() {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter, err := app.rateLimiter.Take(r.Context(), group+":"+key(r), limit)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				app.clientError(w, r, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"github.com/rhysmah/snippet-box/internal/models/mocks"
+	"github.com/rhysmah/snippet-box/internal/ratelimit"
+)
+
+func TestCommonHeaders(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	commonHeaders(next).ServeHTTP(rr, r)
+
+	rs := rr.Result()
+
+	assert.Equal(t, rs.Header.Get("Content-Security-Policy"),
+		"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+	assert.Equal(t, rs.Header.Get("Referrer-Policy"), "origin-when-cross-origin")
+	assert.Equal(t, rs.Header.Get("X-Content-Type-Options"), "nosniff")
+	assert.Equal(t, rs.Header.Get("X-Frame-Options"), "deny")
+	assert.Equal(t, rs.Header.Get("X-XSS-Protection"), "0")
+	assert.Equal(t, rs.Header.Get("Server"), "Go")
+
+	// The next handler in the chain should still have been called
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+	assert.Equal(t, rr.Body.String(), "OK")
+}
+
+func TestRecoverPanic(t *testing.T) {
+	app := newTestApplication(t)
+
+	rr := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	})
+
+	app.recoverPanic(next).ServeHTTP(rr, r)
+
+	rs := rr.Result()
+
+	assert.Equal(t, rs.StatusCode, http.StatusInternalServerError)
+	assert.Equal(t, rs.Header.Get("Connection"), "close")
+}
+
+func TestRecoverPanicAfterResponseStarted(t *testing.T) {
+	app := newTestApplication(t)
+
+	rr := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("oops")
+	})
+
+	// The response is aborted rather than having an error page added to it
+	defer func() {
+		assert.Equal(t, recover() == http.ErrAbortHandler, true)
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.Equal(t, rr.Body.String(), "partial")
+	}()
+
+	app.recoverPanic(next).ServeHTTP(rr, r)
+	t.Fatal("response wasn't aborted")
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		kept   bool
+	}{
+		{"No header", "", false},
+		{"Valid header", "abc-123.XYZ_7", true},
+		{"Invalid header", "abc\nlevel=ERROR", false},
+		{"Too long", string(bytes.Repeat([]byte("a"), 129)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				r.Header.Set("X-Request-ID", tt.header)
+			}
+
+			var seen string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = getRequestID(r)
+			})
+
+			requestID(next).ServeHTTP(rr, r)
+
+			id := rr.Result().Header.Get("X-Request-ID")
+			assert.Equal(t, id, seen)
+			assert.Equal(t, id != "", true)
+			assert.Equal(t, id == tt.header, tt.kept)
+		})
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	app := newTestApplication(t)
+
+	var logs bytes.Buffer
+	app.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	ts := newTestServer(t, app.routes())
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/view/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Request-ID", "test-request")
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	for _, want := range []string{
+		"msg=request",
+		"request_id=test-request",
+		"method=GET",
+		"uri=/snippet/view/1",
+		"status=200",
+		"user_id=0",
+	} {
+		assert.StringContains(t, logs.String(), want)
+	}
+
+	// Probes are only logged at debug level
+	logs.Reset()
+	ts.get(t, "/healthz")
+	assert.Equal(t, logs.Len(), 0)
+}
+
+func TestRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	limit := ratelimit.Limit{Rate: 1.0 / 60, Burst: 2}
+	handler := app.rateLimit("test", limit, app.rateLimitByIP)(next)
+
+	get := func(remoteAddr string) *http.Response {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		handler.ServeHTTP(rr, r)
+		return rr.Result()
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		wantCode   int
+		wantRetry  string
+	}{
+		{"First", "192.0.2.1:1234", http.StatusOK, ""},
+		{"Second", "192.0.2.1:1234", http.StatusOK, ""},
+		{"Over limit", "192.0.2.1:1234", http.StatusTooManyRequests, "60"},
+		{"Other client", "192.0.2.2:1234", http.StatusOK, ""},
+		{"IPv6", "[2001:db8:1:2::1]:1234", http.StatusOK, ""},
+		{"Same /64", "[2001:db8:1:2::2]:1234", http.StatusOK, ""},
+		{"Same /64 over limit", "[2001:db8:1:2:ffff::3]:1234", http.StatusTooManyRequests, "60"},
+		{"Other /64", "[2001:db8:1:3::1]:1234", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		rs := get(tt.remoteAddr)
+		assert.Equal(t, rs.StatusCode, tt.wantCode)
+		assert.Equal(t, rs.Header.Get("Retry-After"), tt.wantRetry)
+	}
+}
+
+func TestRateLimitBeforeSession(t *testing.T) {
+	app := newTestApplication(t)
+	app.limits.dynamic = ratelimit.Limit{Rate: 1.0 / 60, Burst: 1}
+
+	ts := newTestServer(t, app.routes())
+
+	code, _, _ := ts.get(t, "/user/login")
+	assert.Equal(t, code, http.StatusOK)
+
+	// Requests over the limit are turned away before the session and CSRF
+	// middleware run, so a client without cookies isn't given any
+	client := &http.Client{Transport: ts.Client().Transport}
+
+	rs, err := client.Get(ts.URL + "/user/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	assert.Equal(t, rs.StatusCode, http.StatusTooManyRequests)
+	assert.Equal(t, rs.Header.Get("Retry-After"), "60")
+	assert.Equal(t, len(rs.Cookies()), 0)
+}
+
+func TestRequireRole(t *testing.T) {
+	app := newTestApplication(t)
+
+	// One server per user, so each keeps its own session cookie
+	servers := map[string]*testServer{"anonymous": newTestServer(t, app.routes())}
+	for _, email := range []string{"alice@example.com", "bob@example.com", "carol@example.com"} {
+		servers[email] = newTestServer(t, app.routes())
+		servers[email].login(t, email, mocks.MockPassword)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		user     string
+		wantCode int
+	}{
+		{"Protected, anonymous", "/account/sessions", "anonymous", http.StatusSeeOther},
+		{"Protected, user", "/account/sessions", "alice@example.com", http.StatusOK},
+		{"Protected, moderator", "/account/sessions", "bob@example.com", http.StatusOK},
+		{"Protected, admin", "/account/sessions", "carol@example.com", http.StatusOK},
+		{"Moderator, anonymous", "/moderation/reports", "anonymous", http.StatusSeeOther},
+		{"Moderator, user", "/moderation/reports", "alice@example.com", http.StatusForbidden},
+		{"Moderator, moderator", "/moderation/reports", "bob@example.com", http.StatusOK},
+		{"Moderator, admin", "/moderation/reports", "carol@example.com", http.StatusOK},
+		{"Admin, anonymous", "/admin", "anonymous", http.StatusSeeOther},
+		{"Admin, user", "/admin", "alice@example.com", http.StatusForbidden},
+		{"Admin, moderator", "/admin", "bob@example.com", http.StatusForbidden},
+		{"Admin, admin", "/admin", "carol@example.com", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := servers[tt.user].get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/rhysmah/snippet-box/internal/migrations"
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+const migrateUsage = "usage: web [flags] migrate up|down [steps]|status"
+
+// runMigrate() carries out the `migrate` subcommand, which applies or rolls
+// back the schema migrations embedded in the binary:
+//
+//	migrate up           apply every pending migration
+//	migrate down [steps] roll back the last migration (or the last `steps`)
+//	migrate status       list the migrations and whether they're applied
+func runMigrate(w io.Writer, db *models.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := migrations.New(db.DB, string(db.Dialect))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Fprintf(w, "applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "no pending migrations")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Fprintf(w, "rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(rolledBack) == 0 {
+			fmt.Fprintln(w, "no migrations to roll back")
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			applied := "pending"
+			if !s.Applied.IsZero() {
+				applied = "applied " + s.Applied.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d_%-24s %s\n", s.Version, s.Name, applied)
+		}
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+const (
+	// maxReportsPerHour limits how many reports a user, or an anonymous
+	// visitor's IP address, can make per hour.
+	maxReportsPerHour = 10
+
+	// autoHideReporters is the number of independent reporters it takes to
+	// hide a snippet until a moderator has reviewed it.
+	autoHideReporters = 3
+)
+
+func (app *application) moderationQueue(w http.ResponseWriter, r *http.Request) {
+	queue, err := app.reports.Queue(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.ReportQueue = queue
+
+	app.render(w, r, http.StatusOK, "moderation.tmpl.html", data)
+}
+
+func (app *application) moderationDismissPost(w http.ResponseWriter, r *http.Request) {
+	app.moderationDecide(w, r, models.DecisionDismiss)
+}
+
+func (app *application) moderationHidePost(w http.ResponseWriter, r *http.Request) {
+	app.moderationDecide(w, r, models.DecisionHide)
+}
+
+func (app *application) moderationBanPost(w http.ResponseWriter, r *http.Request) {
+	app.moderationDecide(w, r, models.DecisionBan)
+}
+
+// moderationDecide() carries out a moderator's decision about a reported
+// snippet, then records it and closes the snippet's reports.
+func (app *application) moderationDecide(w http.ResponseWriter, r *http.Request, decision string) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	// Find the snippet in the queue; this also gets us its author, which
+	// Get can't do once the snippet has been hidden.
+	queue, err := app.reports.Queue(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var reported *models.ReportedSnippet
+	for i := range queue {
+		if queue[i].SnippetID == id {
+			reported = &queue[i]
+		}
+	}
+	if reported == nil {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	switch decision {
+	case models.DecisionDismiss:
+		// The reports were unfounded, so undo any automatic hiding. A
+		// snippet hidden some other way, e.g. by an admin, stays hidden.
+		err = app.undoAutoHide(r, id)
+	case models.DecisionHide:
+		err = app.snippets.SetHidden(r.Context(), id, true)
+	case models.DecisionBan:
+		err = app.banAuthor(r, reported)
+	}
+	if err != nil {
+		if message, ok := banRefusals[err]; ok {
+			app.sessionManager.Put(r.Context(), "flash", message)
+			http.Redirect(w, r, "/moderation/reports", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.reports.Decide(r.Context(), id, app.authenticatedUserID(r), decision)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.audit(r, models.EventModeration, map[string]any{"snippet_id": id, "decision": decision})
+
+	app.sessionManager.Put(r.Context(), "flash", "Decision recorded.")
+	http.Redirect(w, r, "/moderation/reports", http.StatusSeeOther)
+}
+
+// undoAutoHide() shows a snippet again if it was hidden automatically, and
+// no decision has been made about it since.
+func (app *application) undoAutoHide(r *http.Request, id int) error {
+	latest, err := app.reports.LatestDecision(r.Context(), id)
+	if err != nil || latest != models.DecisionAutoHide {
+		return err
+	}
+
+	return app.snippets.SetHidden(r.Context(), id, false)
+}
+
+var (
+	errNoAuthor = errors.New("reported snippet has no author")
+	errBanSelf  = errors.New("moderator tried to ban themselves")
+	errBanStaff = errors.New("only admins can ban moderators and admins")
+)
+
+// banRefusals explains to the moderator why banAuthor() wouldn't ban a
+// snippet's author.
+var banRefusals = map[error]string{
+	errNoAuthor: "This snippet's author has deleted their account.",
+	errBanSelf:  "You can't ban yourself.",
+	errBanStaff: "Only admins can ban moderators and admins.",
+}
+
+// banAuthor() hides a reported snippet, then disables its author's account
+// and signs them out everywhere. Moderators can only ban ordinary users;
+// it takes an admin to ban another moderator or admin, and nobody can ban
+// themselves.
+func (app *application) banAuthor(r *http.Request, reported *models.ReportedSnippet) error {
+	if reported.AuthorID == 0 {
+		return errNoAuthor
+	}
+
+	if reported.AuthorID == app.authenticatedUserID(r) {
+		return errBanSelf
+	}
+
+	roles, err := app.users.Roles(r.Context(), reported.AuthorID)
+	if err != nil {
+		return err
+	}
+	staff := slices.Contains(roles, models.RoleModerator) || slices.Contains(roles, models.RoleAdmin)
+	if staff && !app.hasRole(r, models.RoleAdmin) {
+		return errBanStaff
+	}
+
+	err = app.snippets.SetHidden(r.Context(), reported.SnippetID, true)
+	if err != nil {
+		return err
+	}
+
+	err = app.users.SetDisabled(r.Context(), reported.AuthorID, true)
+	if err != nil {
+		return err
+	}
+
+	return app.revokeAllSessions(r.Context(), reported.AuthorID, "")
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"github.com/rhysmah/snippet-box/internal/models"
+	"github.com/rhysmah/snippet-box/internal/models/mocks"
+)
+
+func TestModerationDismiss(t *testing.T) {
+	tests := []struct {
+		name       string
+		decisions  []string
+		wantHidden bool
+	}{
+		{
+			name:       "Hidden automatically",
+			decisions:  []string{models.DecisionAutoHide},
+			wantHidden: false,
+		},
+		{
+			name:       "Hidden by an admin",
+			wantHidden: true,
+		},
+		{
+			name:       "Hidden by a moderator",
+			decisions:  []string{models.DecisionAutoHide, models.DecisionHide},
+			wantHidden: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+
+			id := mocks.MockSnippet.ID
+			ctx := t.Context()
+
+			err := app.reports.Insert(t.Context(), id, 1, "192.0.2.1", "spam", "")
+			assert.NilError(t, err)
+			for _, decision := range tt.decisions {
+				err = app.reports.Decide(t.Context(), id, 0, decision)
+				assert.NilError(t, err)
+			}
+			if len(tt.decisions) > 1 {
+				// The moderator's decision closed the first report
+				err = app.reports.Insert(t.Context(), id, 3, "192.0.2.3", "spam", "")
+				assert.NilError(t, err)
+			}
+			err = app.snippets.SetHidden(ctx, id, true)
+			assert.NilError(t, err)
+
+			ts.login(t, "bob@example.com", mocks.MockPassword)
+
+			_, _, body := ts.get(t, "/moderation/reports")
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := ts.postForm(t, "/moderation/reports/1/dismiss", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+
+			_, err = app.snippets.Get(ctx, id)
+			assert.Equal(t, errors.Is(err, models.ErrNoRecord), tt.wantHidden)
+		})
+	}
+}
+
+func TestSnippetReport(t *testing.T) {
+	report := func(t *testing.T, ts *testServer, reason string) (int, http.Header) {
+		t.Helper()
+
+		_, _, body := ts.get(t, "/snippet/view/1")
+
+		form := url.Values{}
+		form.Add("reason", reason)
+		form.Add("details", "Not a haiku")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, header, _ := ts.postForm(t, "/snippet/report/1", form)
+		return code, header
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.routes())
+
+		code, header := report(t, ts, "spam")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/snippet/view/1")
+
+		queue, err := app.reports.Queue(t.Context())
+		assert.NilError(t, err)
+		assert.Equal(t, len(queue), 1)
+		assert.Equal(t, queue[0].Reports[0].Reason, "spam")
+		assert.Equal(t, queue[0].Reports[0].ReporterIP, "127.0.0.1")
+	})
+
+	t.Run("Invalid reason", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.routes())
+
+		code, _ := report(t, ts, "boring")
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+		queue, err := app.reports.Queue(t.Context())
+		assert.NilError(t, err)
+		assert.Equal(t, len(queue), 0)
+	})
+
+	t.Run("Hourly limit", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.routes())
+
+		for range maxReportsPerHour - 1 {
+			err := app.reports.Insert(t.Context(), 1, 0, "127.0.0.1", "spam", "")
+			assert.NilError(t, err)
+		}
+
+		code, _ := report(t, ts, "spam")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		code, _ = report(t, ts, "spam")
+		assert.Equal(t, code, http.StatusTooManyRequests)
+	})
+
+	t.Run("Hidden automatically", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.routes())
+
+		// Reports from the same reporter only count once
+		for _, reporterID := range []int{2, 3, 3} {
+			err := app.reports.Insert(t.Context(), 1, reporterID, "192.0.2.1", "spam", "")
+			assert.NilError(t, err)
+		}
+
+		code, header := report(t, ts, "spam")
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, header.Get("Location"), "/")
+
+		_, err := app.snippets.Get(t.Context(), 1)
+		assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+		reports := app.reports.(*mocks.ReportModel)
+		assert.Equal(t, len(reports.Decisions), 1)
+		assert.Equal(t, reports.Decisions[0], mocks.Decision{SnippetID: 1, Decision: models.DecisionAutoHide})
+
+		// The reports stay open for a moderator to review
+		queue, err := app.reports.Queue(t.Context())
+		assert.NilError(t, err)
+		assert.Equal(t, len(queue[0].Reports), autoHideReporters+1)
+	})
+
+	t.Run("Below the auto-hide threshold", func(t *testing.T) {
+		app := newTestApplication(t)
+		ts := newTestServer(t, app.routes())
+
+		err := app.reports.Insert(t.Context(), 1, 2, "192.0.2.1", "spam", "")
+		assert.NilError(t, err)
+
+		code, _ := report(t, ts, "spam")
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, err = app.snippets.Get(t.Context(), 1)
+		assert.NilError(t, err)
+	})
+}
+
+func TestModerationBan(t *testing.T) {
+	tests := []struct {
+		name         string
+		moderator    string
+		authorID     int
+		wantDisabled bool
+		wantFlash    string
+	}{
+		{"Moderator bans a user", "bob@example.com", 1, true, "Decision recorded."},
+		{"Moderator bans an admin", "bob@example.com", 3, false, "Only admins can ban moderators and admins."},
+		{"Moderator bans themselves", "bob@example.com", 2, false, "You can't ban yourself."},
+		{"Admin bans a moderator", "carol@example.com", 2, true, "Decision recorded."},
+		{"Admin bans themselves", "carol@example.com", 3, false, "You can't ban yourself."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+
+			id, err := app.snippets.Insert(t.Context(), tt.authorID, "Spam", "Buy now", 7)
+			assert.NilError(t, err)
+
+			err = app.reports.Insert(t.Context(), id, 4, "192.0.2.4", "spam", "")
+			assert.NilError(t, err)
+
+			ts.login(t, tt.moderator, mocks.MockPassword)
+
+			_, _, body := ts.get(t, "/moderation/reports")
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, header, _ := ts.postForm(t, fmt.Sprintf("/moderation/reports/%d/ban", id), form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, header.Get("Location"), "/moderation/reports")
+
+			_, _, body = ts.get(t, "/moderation/reports")
+			assert.StringContains(t, body, html.EscapeString(tt.wantFlash))
+
+			author, err := app.users.Get(t.Context(), tt.authorID)
+			assert.NilError(t, err)
+			assert.Equal(t, author.Disabled, tt.wantDisabled)
+
+			// Refused bans leave the snippet visible and its reports open
+			_, err = app.snippets.Get(t.Context(), id)
+			assert.Equal(t, errors.Is(err, models.ErrNoRecord), tt.wantDisabled)
+		})
+	}
+}
@@ -4,32 +4,93 @@ import (
 	"net/http"
 
 	"github.com/justinas/alice"
+	"github.com/rhysmah/snippet-box/internal/models"
 )
 
 func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
 
-	// Creates a file server that serves files out of the "./ui/static" directory.
-	fileServer := http.FileServer(http.Dir("./ui/static/"))
-	mux.Handle("GET /static/", http.StripPrefix("/static", fileServer))
+	// Static files are embedded in the binary; see serveStatic()
+	mux.HandleFunc("GET /static/{path...}", app.serveStatic)
 
-	// Unprotected routes
-	dynamic := alice.New(app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+	// Probes for load balancers and orchestrators; these skip the session,
+	// CSRF and rate limiting middleware
+	mux.HandleFunc("GET /healthz", app.healthz)
+	mux.HandleFunc("GET /readyz", app.readyz)
+	mux.HandleFunc("GET /version", app.version)
+
+	// Unless they're served on their own address; see metricsRoutes()
+	if app.metricsAddr == "" {
+		mux.Handle("GET /metrics", app.metrics.handler())
+	}
+
+	// Unprotected routes. Each group's middleware is kept apart from the
+	// chain its routes use, which ends with traceHandler(), so the groups
+	// below can build on it. Rate limiting comes first, so requests over
+	// the limit don't touch the session store or the database.
+	dynamicMiddleware := alice.New(app.rateLimit("dynamic", app.limits.dynamic, app.rateLimitByIP),
+		app.sessionManager.LoadAndSave, app.noSurf, app.authenticate)
+	dynamic := dynamicMiddleware.Append(app.traceHandler)
 
 	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home)) // Requires exact match
 	mux.Handle("GET /snippet/view/{id}", dynamic.ThenFunc(app.snippetView))
+	mux.Handle("POST /snippet/report/{id}", dynamic.ThenFunc(app.snippetReportPost))
 	mux.Handle("GET /user/signup", dynamic.ThenFunc(app.userSignup))
 	mux.Handle("POST /user/signup", dynamic.ThenFunc(app.userSignupPost))
 	mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLogin))
 	mux.Handle("POST /user/login", dynamic.ThenFunc(app.userLoginPost))
 
 	// protected (authenticated-only) routes
-	protected := dynamic.Append(app.requireAuthentication)
+	protectedMiddleware := dynamicMiddleware.Append(app.requireAuthentication,
+		app.rateLimit("protected", app.limits.protected, app.rateLimitByUser))
+	protected := protectedMiddleware.Append(app.traceHandler)
 
 	mux.Handle("GET /snippet/create", protected.ThenFunc(app.snippetCreate))
 	mux.Handle("POST /snippet/create", protected.ThenFunc(app.snippetCreatePost))
 	mux.Handle("POST /user/logout", protected.ThenFunc(app.userLogoutPost))
+	mux.Handle("GET /account/sessions", protected.ThenFunc(app.accountSessions))
+	mux.Handle("POST /account/sessions/revoke", protected.ThenFunc(app.accountSessionRevokePost))
+	mux.Handle("POST /account/sessions/revoke-all", protected.ThenFunc(app.accountSessionRevokeAllPost))
+	mux.Handle("GET /account/export", protected.ThenFunc(app.accountExport))
+	mux.Handle("GET /account/delete", protected.ThenFunc(app.accountDelete))
+	mux.Handle("POST /account/delete", protected.ThenFunc(app.accountDeletePost))
+
+	// moderator routes
+	moderator := protectedMiddleware.Append(app.requireRole(models.RoleModerator, models.RoleAdmin), app.traceHandler)
+
+	mux.Handle("GET /moderation/reports", moderator.ThenFunc(app.moderationQueue))
+	mux.Handle("POST /moderation/reports/{id}/dismiss", moderator.ThenFunc(app.moderationDismissPost))
+	mux.Handle("POST /moderation/reports/{id}/hide", moderator.ThenFunc(app.moderationHidePost))
+	mux.Handle("POST /moderation/reports/{id}/ban", moderator.ThenFunc(app.moderationBanPost))
+
+	// admin-only routes
+	admin := protectedMiddleware.Append(app.requireRole(models.RoleAdmin), app.traceHandler)
+
+	mux.Handle("GET /admin", admin.ThenFunc(app.adminDashboard))
+	mux.Handle("GET /admin/users", admin.ThenFunc(app.adminUsers))
+	mux.Handle("POST /admin/users/{id}/disable", admin.ThenFunc(app.adminUserDisablePost))
+	mux.Handle("POST /admin/users/{id}/enable", admin.ThenFunc(app.adminUserEnablePost))
+	mux.Handle("POST /admin/users/{id}/logout", admin.ThenFunc(app.adminUserLogoutPost))
+	mux.Handle("GET /admin/snippets", admin.ThenFunc(app.adminSnippets))
+	mux.Handle("POST /admin/snippets/{id}/hide", admin.ThenFunc(app.adminSnippetHidePost))
+	mux.Handle("POST /admin/snippets/{id}/unhide", admin.ThenFunc(app.adminSnippetUnhidePost))
+	mux.Handle("POST /admin/snippets/{id}/delete", admin.ThenFunc(app.adminSnippetDeletePost))
+	mux.Handle("GET /admin/audit", admin.ThenFunc(app.adminAudit))
+	mux.Handle("GET /admin/audit/export", admin.ThenFunc(app.adminAuditExport))
+
+	// traceRequest() must come first, so every other span is part of the
+	// request's, and instrument() last, right before the mux; see its comment.
+	// logRequest() comes before recoverPanic() so it sees the 500s it sends,
+	// and before compress() so it logs the bytes actually sent.
+	standard := alice.New(app.traceRequest, requestID, app.realIP, app.logRequest, app.recoverPanic, commonHeaders, compress, app.instrument)
+	return standard.Then(app.unmatched(mux))
+}
+
+// metricsRoutes() returns the handler for the separate metrics server,
+// which is only used if a metrics address is configured.
+func (app *application) metricsRoutes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", app.metrics.handler())
 
-	standard := alice.New(app.recoverPanic, app.logRequest, commonHeaders)
-	return standard.Then(mux)
+	return app.traceRequest(app.recoverPanic(mux))
 }
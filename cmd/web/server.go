@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// serve() runs the server, using listen to start it, until ctx is
+// cancelled; main() cancels it on SIGINT or SIGTERM. The server then stops
+// accepting connections and waits up to `timeout` for in-flight requests to
+// finish. After that it closes their connections and returns an error
+// without waiting any longer, so the handlers of requests it gave up on may
+// still be running.
+func (app *application) serve(ctx context.Context, srv *http.Server, listen func() error, timeout time.Duration) error {
+	listenErr := make(chan error, 1)
+
+	go func() {
+		listenErr <- listen()
+	}()
+
+	select {
+	case err := <-listenErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	app.logger.Info("shutting down server", slog.String("addr", srv.Addr), slog.Duration("timeout", timeout))
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Shutdown() closes the listeners, so listen returns straight away, then
+	// waits for active connections to go idle
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		app.logger.Warn("shutdown timed out, abandoning in-flight requests", slog.String("addr", srv.Addr))
+		srv.Close()
+		return fmt.Errorf("shutting down server: %w", err)
+	}
+
+	err = <-listenErr
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	app.logger.Info("server stopped", slog.String("addr", srv.Addr))
+
+	return nil
+}
+
+// cleanupStopper is implemented by stores which clear out expired data in a
+// background goroutine: the rate limit stores and the scs session stores.
+type cleanupStopper interface {
+	StopCleanup()
+}
+
+// stopBackgroundWorkers() stops the cleanup goroutines of any stores which
+// have them. It's only safe to call once the server has stopped.
+func stopBackgroundWorkers(stores ...any) {
+	for _, store := range stores {
+		if s, ok := store.(cleanupStopper); ok {
+			s.StopCleanup()
+		}
+	}
+}
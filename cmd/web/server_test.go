@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestServeGracefulShutdown(t *testing.T) {
+	app := newTestApplication(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.Write([]byte("OK"))
+		}),
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.serve(ctx, srv, func() error { return srv.Serve(ln) }, 5*time.Second)
+	}()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		rs, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer rs.Body.Close()
+
+		body, err := io.ReadAll(rs.Body)
+		done <- result{rs.StatusCode, string(body), err}
+	}()
+
+	// Start shutting down while the request is still being handled
+	<-started
+	cancel()
+
+	// serve() must wait for the request rather than returning straight away
+	select {
+	case err := <-serveErr:
+		t.Fatalf("serve returned before the request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// New connections are refused once shutdown has begun
+	_, err = net.DialTimeout("tcp", ln.Addr().String(), time.Second)
+	assert.Equal(t, err != nil, true)
+
+	close(release)
+
+	res := <-done
+	assert.NilError(t, res.err)
+	assert.Equal(t, res.status, http.StatusOK)
+	assert.Equal(t, res.body, "OK")
+
+	assert.NilError(t, <-serveErr)
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	app := newTestApplication(t)
+
+	var logs bytes.Buffer
+	app.logger = slog.New(slog.NewTextHandler(&logs, nil))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		}),
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.serve(ctx, srv, func() error { return srv.Serve(ln) }, 50*time.Millisecond)
+	}()
+
+	go http.Get("http://" + ln.Addr().String())
+
+	<-started
+	cancel()
+
+	// A request which outlasts the timeout doesn't hold up shutdown forever
+	err = <-serveErr
+	assert.Equal(t, err != nil, true)
+
+	// The request is still running, and the log says so
+	assert.StringContains(t, logs.String(), "abandoning in-flight requests")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/cache"
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+// cachedSnippetStore wraps a snippet store to keep the snippets people view,
+// and the latest snippets shown on the home page, in memory. Only Get and
+// Latest are cached. Both only return snippets which anyone may see, and
+// cached snippets are dropped no later than when they expire. Other methods,
+// such as ForUser, go straight to the store.
+//
+// Changes made through the store drop whatever they could have affected.
+// Changes made another way, e.g. by another instance of the application,
+// show once the cached copies reach the TTL.
+type cachedSnippetStore struct {
+	models.SnippetStore
+
+	snippets *cache.LRU[int, models.Snippet]
+	latest   *cache.LRU[struct{}, []models.Snippet]
+	metrics  *metrics
+
+	// generation counts changes to snippets. A lookup which misses only
+	// caches what it read if there have been no changes since it started,
+	// so a snippet hidden or deleted during the lookup can't be cached.
+	mu         sync.Mutex
+	generation uint64
+}
+
+func newCachedSnippetStore(store models.SnippetStore, size int, ttl time.Duration, metrics *metrics) *cachedSnippetStore {
+	return &cachedSnippetStore{
+		SnippetStore: store,
+		snippets:     cache.New[int, models.Snippet](size, ttl),
+		latest:       cache.New[struct{}, []models.Snippet](1, ttl),
+		metrics:      metrics,
+	}
+}
+
+func (s *cachedSnippetStore) Get(ctx context.Context, id int) (models.Snippet, error) {
+	if snippet, ok := s.snippets.Get(id); ok {
+		s.metrics.cacheLookups.WithLabelValues("snippet", "hit").Inc()
+		return snippet, nil
+	}
+	s.metrics.cacheLookups.WithLabelValues("snippet", "miss").Inc()
+
+	generation := s.currentGeneration()
+
+	snippet, err := s.SnippetStore.Get(ctx, id)
+	if err != nil {
+		return models.Snippet{}, err
+	}
+
+	s.store(generation, func() {
+		s.snippets.Set(id, snippet, snippet.Expires)
+	})
+
+	return snippet, nil
+}
+
+func (s *cachedSnippetStore) Latest(ctx context.Context) ([]models.Snippet, error) {
+	// Callers get their own copy, so they can't change the cached one
+	if snippets, ok := s.latest.Get(struct{}{}); ok {
+		s.metrics.cacheLookups.WithLabelValues("latest", "hit").Inc()
+		return slices.Clone(snippets), nil
+	}
+	s.metrics.cacheLookups.WithLabelValues("latest", "miss").Inc()
+
+	generation := s.currentGeneration()
+
+	snippets, err := s.SnippetStore.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The list changes as soon as any of its snippets expires
+	var expires time.Time
+	for _, snippet := range snippets {
+		if expires.IsZero() || snippet.Expires.Before(expires) {
+			expires = snippet.Expires
+		}
+	}
+
+	s.store(generation, func() {
+		s.latest.Set(struct{}{}, slices.Clone(snippets), expires)
+	})
+
+	return snippets, nil
+}
+
+func (s *cachedSnippetStore) Insert(ctx context.Context, userID int, title, content string, expires int) (int, error) {
+	id, err := s.SnippetStore.Insert(ctx, userID, title, content, expires)
+	s.invalidate(0)
+	return id, err
+}
+
+func (s *cachedSnippetStore) SetHidden(ctx context.Context, id int, hidden bool) error {
+	err := s.SnippetStore.SetHidden(ctx, id, hidden)
+	s.invalidate(id)
+	return err
+}
+
+func (s *cachedSnippetStore) Delete(ctx context.Context, id int) error {
+	err := s.SnippetStore.Delete(ctx, id)
+	s.invalidate(id)
+	return err
+}
+
+// Purge empties the cache, after snippets have been changed some other way
+// than through the store, such as by deleting a user's account.
+func (s *cachedSnippetStore) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	s.snippets.Purge()
+	s.latest.Purge()
+}
+
+// invalidate() drops the snippet with the given ID, if it isn't zero, and
+// the latest snippets. It's called once a change has been made, whether or
+// not it succeeded, as a failed change may still have been applied.
+func (s *cachedSnippetStore) invalidate(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	if id != 0 {
+		s.snippets.Delete(id)
+	}
+	s.latest.Purge()
+}
+
+func (s *cachedSnippetStore) currentGeneration() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.generation
+}
+
+// store() runs set, which adds to the cache, unless snippets have changed
+// since generation.
+func (s *cachedSnippetStore) store(generation uint64, set func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.generation == generation {
+		set()
+	}
+}
+
+// purgeSnippetCache() empties the snippet cache, if there is one.
+func (app *application) purgeSnippetCache() {
+	if s, ok := app.snippets.(*cachedSnippetStore); ok {
+		s.Purge()
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"github.com/rhysmah/snippet-box/internal/models"
+	"github.com/rhysmah/snippet-box/internal/models/mocks"
+)
+
+func TestCachedSnippetStore(t *testing.T) {
+	metrics := newMetrics(nil)
+	store := newCachedSnippetStore(mocks.NewSnippetModel(), 10, time.Minute, metrics)
+
+	lookups := func(cache, result string) int {
+		return int(testutil.ToFloat64(metrics.cacheLookups.WithLabelValues(cache, result)))
+	}
+
+	ctx := t.Context()
+
+	// The first lookup misses, and the rest hit
+	for range 3 {
+		s, err := store.Get(ctx, 1)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Title, mocks.MockSnippet.Title)
+	}
+	assert.Equal(t, lookups("snippet", "miss"), 1)
+	assert.Equal(t, lookups("snippet", "hit"), 2)
+
+	latest, err := store.Latest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 1)
+
+	// Inserting a snippet changes the latest snippets
+	id, err := store.Insert(ctx, 1, "O snail", "Climb Mount Fuji", 7)
+	assert.NilError(t, err)
+
+	latest, err = store.Latest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 2)
+	assert.Equal(t, lookups("latest", "miss"), 2)
+
+	// Hidden snippets are no longer served from the cache
+	_, err = store.Get(ctx, id)
+	assert.NilError(t, err)
+
+	err = store.SetHidden(ctx, id, true)
+	assert.NilError(t, err)
+
+	_, err = store.Get(ctx, id)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+
+	latest, err = store.Latest(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(latest), 1)
+
+	// Nor are deleted ones
+	err = store.Delete(ctx, 1)
+	assert.NilError(t, err)
+
+	_, err = store.Get(ctx, 1)
+	assert.Equal(t, errors.Is(err, models.ErrNoRecord), true)
+}
+
+func TestCachedSnippetStoreCopiesLatest(t *testing.T) {
+	store := newCachedSnippetStore(mocks.NewSnippetModel(), 10, time.Minute, newMetrics(nil))
+
+	latest, err := store.Latest(t.Context())
+	assert.NilError(t, err)
+	latest[0].Title = "Changed"
+
+	latest, err = store.Latest(t.Context())
+	assert.NilError(t, err)
+	assert.Equal(t, latest[0].Title, mocks.MockSnippet.Title)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/rhysmah/snippet-box/ui"
+)
+
+// staticFiles are the files embedded under ui/static. Each is served both at
+// its own path, e.g. /static/css/main.css, and at a fingerprinted path with a
+// hash of its contents in the name, e.g. /static/css/main.3f2a1b9c.css.
+// Pages link to the fingerprinted paths, which change whenever the file
+// does, so browsers can cache them for as long as they like.
+type staticFiles struct {
+	fsys    fs.FS
+	hashes  map[string]string            // File name to hash of its contents
+	names   map[string]string            // Fingerprinted name to file name
+	encoded map[string]map[string][]byte // File name to compressed contents, by content coding
+}
+
+// newStaticFiles() hashes every file embedded under ui/static, and
+// compresses those which compress well, ahead of time.
+func newStaticFiles() (*staticFiles, error) {
+	fsys, err := fs.Sub(ui.Files, "static")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &staticFiles{
+		fsys:    fsys,
+		hashes:  map[string]string{},
+		names:   map[string]string{},
+		encoded: map[string]map[string][]byte{},
+	}
+
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(b)
+		hash := hex.EncodeToString(sum[:4])
+
+		s.hashes[name] = hash
+		s.names[fingerprint(name, hash)] = name
+
+		if compressible(mime.TypeByExtension(path.Ext(name))) {
+			s.encoded[name], err = precompress(b)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// precompress() compresses b with each of our encodings, at their slowest
+// and best settings. Encodings which don't make b any smaller are left out.
+func precompress(b []byte) (map[string][]byte, error) {
+	encoded := map[string][]byte{}
+
+	for _, encoding := range encodings {
+		var (
+			buf bytes.Buffer
+			enc io.WriteCloser
+		)
+		switch encoding {
+		case "br":
+			enc = brotli.NewWriterLevel(&buf, brotli.BestCompression)
+		case "gzip":
+			enc, _ = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		}
+
+		_, err := enc.Write(b)
+		if err != nil {
+			return nil, err
+		}
+		err = enc.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if buf.Len() < len(b) {
+			encoded[encoding] = buf.Bytes()
+		}
+	}
+
+	return encoded, nil
+}
+
+// fingerprint() adds hash to a file name, before its extension.
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// url() returns the fingerprinted URL of a static file, for use in templates
+// as {{asset "css/main.css"}}.
+func (s *staticFiles) url(name string) (string, error) {
+	hash, ok := s.hashes[name]
+	if !ok {
+		return "", fmt.Errorf("no static file named %q", name)
+	}
+
+	return "/static/" + fingerprint(name, hash), nil
+}
+
+// serveStatic() serves the static file named in the request's path. Files
+// requested by their fingerprinted names never change, so they're cached for
+// a year; anything else must be revalidated, which the ETag makes cheap.
+// Files compressed ahead of time are sent that way to clients which accept
+// it. Only files are served, so there are no directory listings.
+func (app *application) serveStatic(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("path")
+
+	cacheControl := "no-cache"
+	if original, ok := app.static.names[name]; ok {
+		name = original
+		cacheControl = "public, max-age=31536000, immutable"
+	}
+
+	hash, ok := app.static.hashes[name]
+	if !ok {
+		app.clientError(w, r, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControl)
+
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if b, ok := app.static.encoded[name][encoding]; ok {
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Set("ETag", `"`+hash+"-"+encoding+`"`)
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(b))
+		return
+	}
+
+	w.Header().Set("ETag", `"`+hash+`"`)
+	http.ServeFileFS(w, r, app.static.fsys, name)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestStaticFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	// Pages link to the fingerprinted stylesheet
+	_, _, body := ts.get(t, "/")
+	cssURL := regexp.MustCompile(`/static/css/main\.[0-9a-f]{8}\.css`).FindString(body)
+	assert.Equal(t, cssURL != "", true)
+
+	url, err := app.static.url("css/main.css")
+	assert.NilError(t, err)
+	assert.Equal(t, url, cssURL)
+
+	tests := []struct {
+		name             string
+		urlPath          string
+		wantCode         int
+		wantCacheControl string
+	}{
+		{"Fingerprinted", cssURL, http.StatusOK, "public, max-age=31536000, immutable"},
+		{"Plain", "/static/css/main.css", http.StatusOK, "no-cache"},
+		{"Stale fingerprint", "/static/css/main.00000000.css", http.StatusNotFound, ""},
+		{"Directory", "/static/css/", http.StatusNotFound, ""},
+		{"Root", "/static/", http.StatusNotFound, ""},
+		{"Missing", "/static/css/missing.css", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, header.Get("Cache-Control"), tt.wantCacheControl)
+		})
+	}
+
+	// Revalidating an unchanged file is answered without sending it again
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/static/css/main.css", nil)
+	assert.NilError(t, err)
+
+	rs, err := ts.Client().Do(req)
+	assert.NilError(t, err)
+	rs.Body.Close()
+
+	req.Header.Set("If-None-Match", rs.Header.Get("ETag"))
+	rs, err = ts.Client().Do(req)
+	assert.NilError(t, err)
+	rs.Body.Close()
+
+	assert.Equal(t, rs.StatusCode, http.StatusNotModified)
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, fingerprint("css/main.css", "1a2b3c4d"), "css/main.1a2b3c4d.css")
+	assert.Equal(t, fingerprint("LICENSE", "1a2b3c4d"), "LICENSE.1a2b3c4d")
+}
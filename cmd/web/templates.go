@@ -18,7 +18,9 @@ var functions = template.FuncMap{
 	"humanDate": humanDate,
 }
 
-func newTemplateCache() (map[string]*template.Template, error) {
+// newTemplateCache() parses every page, along with the base layout and the
+// partials. Pages link to static files with {{asset "css/main.css"}}.
+func newTemplateCache(static *staticFiles) (map[string]*template.Template, error) {
 	cache := map[string]*template.Template{}
 
 	pages, err := fs.Glob(ui.Files, "html/pages/*.tmpl.html")
@@ -35,7 +37,7 @@ func newTemplateCache() (map[string]*template.Template, error) {
 			page,
 		}
 
-		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, patterns...)
+		ts, err := template.New(name).Funcs(functions).Funcs(template.FuncMap{"asset": static.url}).ParseFS(ui.Files, patterns...)
 		if err != nil {
 			return nil, err
 		}
@@ -58,5 +60,24 @@ type templateData struct {
 	Form            any
 	Flash           string
 	IsAuthenticated bool
+	IsAdmin         bool
+	IsModerator     bool
 	CSRFToken       string
+
+	UserSessions     []models.UserSession
+	CurrentSessionID int
+
+	Users      []models.UserSummary
+	Search     string
+	AdminStats adminStats
+	PrevPage   int // Zero if there's no previous page
+	NextPage   int // Zero if there's no next page
+
+	ReportQueue   []models.ReportedSnippet
+	ReportReasons []string
+
+	AuditEvents     []models.AuditEvent
+	AuditEventTypes []string
+
+	Error errorData
 }
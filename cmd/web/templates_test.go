@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestHumanDate(t *testing.T) {
+	tests := []struct {
+		name string
+		tm   time.Time
+		want string
+	}{
+		{
+			name: "UTC",
+			tm:   time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC),
+			want: "17 Mar 2024 at 10:15",
+		},
+		{
+			name: "Midnight",
+			tm:   time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+			want: "01 Dec 2024 at 00:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, humanDate(tt.tm), tt.want)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/go-playground/form/v4"
+	"github.com/rhysmah/snippet-box/internal/models/mocks"
+	"github.com/rhysmah/snippet-box/internal/ratelimit"
+	"github.com/rhysmah/snippet-box/internal/realip"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// newTestApplication() returns an application backed by the in-memory
+// mock stores, with rate limiting switched off. Sessions are kept in
+// scs's in-memory store.
+func newTestApplication(t *testing.T) *application {
+	static, err := newStaticFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templateCache, err := newTemplateCache(static)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secretScanner, err := newSecretScanner("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rateLimiter := ratelimit.NewMemoryStore(time.Minute)
+	t.Cleanup(rateLimiter.StopCleanup)
+
+	metrics := newMetrics(nil)
+
+	// memstore's StopCleanup() races with its cleanup goroutine, so the
+	// goroutine is left running, as it is with scs's default store
+	sessionStore := memstore.New()
+
+	sessionManager := scs.New()
+	sessionManager.Store = &instrumentedStore{Store: sessionStore, metrics: metrics}
+	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Cookie.Secure = true
+
+	snippets := mocks.NewSnippetModel()
+
+	return &application{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		snippets:       snippets,
+		users:          mocks.NewUserModel(),
+		userSessions:   mocks.NewUserSessionModel(),
+		reports:        mocks.NewReportModel(snippets),
+		auditLog:       mocks.NewAuditModel(),
+		secrets:        secretScanner,
+		rateLimiter:    rateLimiter,
+		ipResolver:     &realip.Resolver{},
+		static:         static,
+		templateCache:  templateCache,
+		formDecoder:    form.NewDecoder(),
+		sessionManager: sessionManager,
+		metrics:        metrics,
+		tracer:         noop.NewTracerProvider().Tracer(""),
+	}
+}
+
+// testServer wraps a TLS test server whose client keeps cookies between
+// requests and doesn't follow redirects, so tests can check them.
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewTLSServer(h)
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+// get() makes a GET request to the test server, returning the response
+// status code, headers and body.
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	t.Helper()
+
+	rs, err := ts.Client().Get(ts.URL + urlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return readResponse(t, rs)
+}
+
+// postForm() POSTs form data to the test server. Callers need to include
+// a valid csrf_token, e.g. from extractCSRFToken().
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	t.Helper()
+
+	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return readResponse(t, rs)
+}
+
+// login() logs in through the login form as the given user.
+func (ts *testServer) login(t *testing.T, email, password string) {
+	t.Helper()
+
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("logging in as %s: got status %d", email, code)
+	}
+}
+
+func readResponse(t *testing.T, rs *http.Response) (int, http.Header, string) {
+	t.Helper()
+
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = bytes.TrimSpace(body)
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+?)'>`)
+
+// extractCSRFToken() pulls the CSRF token out of the first form in a page.
+func extractCSRFToken(t *testing.T, body string) string {
+	t.Helper()
+
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no csrf token found in body")
+	}
+
+	return html.UnescapeString(matches[1])
+}
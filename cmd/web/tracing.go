@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rhysmah/snippet-box/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceRequest() starts the span covering the whole request. If the client
+// sent W3C trace context headers, the span joins the client's trace.
+// The span is named after the request's method until instrument() learns
+// which route matched.
+func (app *application) traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		method := r.Method
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+
+		ctx, span := app.tracer.Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.URLPath(r.URL.Path),
+			))
+		defer span.End()
+
+		// Middleware further in start spans of their own, so keep hold of
+		// this one separately for instrument()
+		ctx = context.WithValue(ctx, requestSpanContextKey, span)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// nameRequestSpan() names the request's span after the pattern of the
+// route which handled it, e.g. "GET /snippet/view/{id}", and records the
+// response's status. It's called by instrument(), the only middleware which
+// sees both. Requests which matched no route keep the method as their name.
+func nameRequestSpan(span trace.Span, pattern string, status int) {
+	if pattern != "" {
+		span.SetName(pattern)
+
+		// The route attribute is just the path part of the pattern
+		route := pattern
+		if _, path, ok := strings.Cut(pattern, " "); ok {
+			route = path
+		}
+		span.SetAttributes(semconv.HTTPRoute(route))
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+
+	if status >= 500 {
+		span.SetStatus(codes.Error, strconv.Itoa(status))
+	}
+}
+
+// startSpan() starts a span as a child of the request's current span, and
+// returns a copy of the request carrying the new span in its context.
+func (app *application) startSpan(r *http.Request, name string) (*http.Request, trace.Span) {
+	ctx, span := app.tracer.Start(r.Context(), name)
+	return r.WithContext(ctx), span
+}
+
+// traceHandler() starts a span for the handler itself, named after the route
+// it's registered for. It must come last in each chain of middleware.
+func (app *application) traceHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, span := app.startSpan(r, "handler "+r.Pattern)
+		defer span.End()
+
+		next.ServeHTTP(w, r)
+	})
+}
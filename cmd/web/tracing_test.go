@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"github.com/rhysmah/snippet-box/internal/tracing"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	app := newTestApplication(t)
+
+	spans := tracetest.NewSpanRecorder()
+	app.tracer = tracing.NewProvider(spans, tracing.Options{SampleRatio: 1}).Tracer("test")
+
+	var logs bytes.Buffer
+	app.logger = slog.New(tracing.LogHandler{Handler: slog.NewTextHandler(&logs, nil)})
+
+	ts := newTestServer(t, app.routes())
+
+	// The request arrives as part of a trace started by the client
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/view/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	assert.Equal(t, rs.StatusCode, http.StatusOK)
+
+	ended := spans.Ended()
+
+	names := map[string]bool{}
+	for _, span := range ended {
+		names[span.Name()] = true
+
+		// Every span belongs to the client's trace
+		assert.Equal(t, span.SpanContext().TraceID().String(), traceID)
+	}
+
+	for _, want := range []string{
+		"GET /snippet/view/{id}",
+		"recoverPanic",
+		"logRequest",
+		"authenticate",
+		"handler GET /snippet/view/{id}",
+		"render view.tmpl.html",
+	} {
+		assert.Equal(t, names[want], true)
+	}
+
+	// The request's span is the last to end, and continues the client's span
+	root := ended[len(ended)-1]
+	assert.Equal(t, root.Name(), "GET /snippet/view/{id}")
+	assert.Equal(t, root.Parent().SpanID().String(), "00f067aa0ba902b7")
+
+	// Log records written during the request carry its trace ID
+	assert.StringContains(t, logs.String(), "trace_id="+traceID)
+}
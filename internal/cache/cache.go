@@ -0,0 +1,119 @@
+// Package cache provides a bounded, in-memory cache whose entries expire.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// LRU holds up to a fixed number of entries, evicting the least recently
+// used when it's full. Entries also expire, after the cache's TTL or
+// sooner if they're set with an earlier expiry. Expired entries are never
+// returned, and are removed when they're next looked up or evicted. An LRU
+// is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List // Most recently used at the front
+	entries map[K]*list.Element
+
+	now func() time.Time // Replaced in tests
+}
+
+// New returns an LRU holding at most size entries, each for at most ttl.
+func New[K comparable, V any](size int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[K]*list.Element, size),
+		now:     time.Now,
+	}
+}
+
+// Get returns the value for key, if it's cached and hasn't expired.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		var zero V
+		return zero, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Set caches value for key until the TTL runs out, or until expires if
+// that's sooner. Pass a zero expires to use the TTL alone.
+func (c *LRU[K, V]) Set(key K, value V, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	limit := c.now().Add(c.ttl)
+	if expires.IsZero() || expires.After(limit) {
+		expires = limit
+	}
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = &entry[K, V]{key: key, value: value, expires: expires}
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete removes key from the cache, if it's there.
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Purge empties the cache.
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	clear(c.entries)
+}
+
+// Len returns the number of entries in the cache, including any which have
+// expired but haven't been removed yet.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRU[K, V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+// newTestLRU returns an LRU whose clock only moves when the test says so.
+func newTestLRU(size int, ttl time.Duration) (*LRU[string, int], *time.Time) {
+	now := time.Date(2024, time.March, 17, 10, 15, 0, 0, time.UTC)
+
+	c := New[string, int](size, ttl)
+	c.now = func() time.Time { return now }
+
+	return c, &now
+}
+
+func TestEviction(t *testing.T) {
+	c, _ := newTestLRU(2, time.Hour)
+
+	c.Set("a", 1, time.Time{})
+	c.Set("b", 2, time.Time{})
+
+	// Using a makes b the least recently used, so it goes first
+	_, ok := c.Get("a")
+	assert.Equal(t, ok, true)
+
+	c.Set("c", 3, time.Time{})
+	assert.Equal(t, c.Len(), 2)
+
+	_, ok = c.Get("b")
+	assert.Equal(t, ok, false)
+
+	v, ok := c.Get("a")
+	assert.Equal(t, ok, true)
+	assert.Equal(t, v, 1)
+
+	// Replacing an entry doesn't count against the size
+	c.Set("c", 4, time.Time{})
+	v, _ = c.Get("c")
+	assert.Equal(t, v, 4)
+	assert.Equal(t, c.Len(), 2)
+}
+
+func TestExpiry(t *testing.T) {
+	c, now := newTestLRU(10, time.Minute)
+
+	c.Set("ttl", 1, time.Time{})
+	c.Set("sooner", 2, now.Add(10*time.Second))
+	c.Set("later", 3, now.Add(time.Hour))
+
+	*now = now.Add(10 * time.Second)
+
+	_, ok := c.Get("sooner")
+	assert.Equal(t, ok, false)
+
+	_, ok = c.Get("ttl")
+	assert.Equal(t, ok, true)
+
+	// An expiry after the TTL is cut short by it
+	*now = now.Add(time.Minute)
+
+	_, ok = c.Get("later")
+	assert.Equal(t, ok, false)
+
+	_, ok = c.Get("ttl")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, c.Len(), 0)
+}
+
+func TestDeleteAndPurge(t *testing.T) {
+	c, _ := newTestLRU(10, time.Minute)
+
+	c.Set("a", 1, time.Time{})
+	c.Set("b", 2, time.Time{})
+
+	c.Delete("a")
+	_, ok := c.Get("a")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, c.Len(), 1)
+
+	c.Purge()
+	_, ok = c.Get("b")
+	assert.Equal(t, ok, false)
+	assert.Equal(t, c.Len(), 0)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	c := New[string, int](50, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for j := range 1000 {
+				key := strconv.Itoa((i * j) % 100)
+				c.Set(key, j, time.Time{})
+				c.Get(key)
+				if j%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, c.Len() <= 50, true)
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func writeFile(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+
+	err := os.WriteFile(path, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+addr = ":5000"
+
+[db]
+driver = "sqlite"
+
+[session]
+lifetime = "2h"
+
+[server]
+read_timeout = "1s"
+write_timeout = "2s"
+`)
+
+	t.Setenv("SNIPPETBOX_CONFIG", path)
+	t.Setenv("SNIPPETBOX_ADDR", ":6000")
+	t.Setenv("SNIPPETBOX_READ_TIMEOUT", "3s")
+	t.Setenv("SNIPPETBOX_ADMIN_PASSWORD_FILE", writeFile(t, "password", "s3cret\n"))
+
+	cfg, args, err := Load([]string{"-addr", ":7000", "migrate", "up"})
+	assert.NilError(t, err)
+
+	assert.Equal(t, cfg.Addr, ":7000")                      // Flag beats env and file
+	assert.Equal(t, cfg.Server.ReadTimeout, 3*time.Second)  // Env beats file
+	assert.Equal(t, cfg.Server.WriteTimeout, 2*time.Second) // File beats default
+	assert.Equal(t, cfg.Server.IdleTimeout, time.Minute)    // Default
+	assert.Equal(t, cfg.Session.Lifetime, 2*time.Hour)      // From file
+	assert.Equal(t, cfg.DB.DSN, "file:snippetbox.db")       // Default for the driver
+	assert.Equal(t, cfg.Admin.Password, "s3cret")           // From a file, without the newline
+	assert.Equal(t, cfg.Cache.Enabled, false)               // Off unless asked for
+	assert.Equal(t, strings.Join(args, " "), "migrate up")
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		file    string
+		wantErr string
+	}{
+		{"Unknown driver", []string{"-db-driver", "oracle"}, "", "unsupported database driver"},
+		{"Bcrypt cost", []string{"-bcrypt-cost", "3"}, "", "bcrypt_cost must be between"},
+		{"Rate limit store", []string{"-db-driver", "sqlite", "-ratelimit-store", "mysql"}, "", "needs the mysql database driver"},
+		{"Trusted proxies", []string{"-trusted-proxies", "nonsense"}, "", "trusted_proxies"},
+		{"Proxy header", []string{"-proxy-header", "x-real-ip"}, "", "proxy_header"},
+		{"Log format", []string{"-log-format", "xml"}, "", "log format"},
+		{"Cache size", []string{"-cache", "-cache-size", "0"}, "", "cache size must be positive"},
+		{"Unknown setting", nil, "adr = \":5000\"", "unknown setting \"adr\""},
+		{"Bad limit", nil, "[ratelimit]\ndynamic = \"5/x\"", "invalid unit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, "config.toml", tt.file)}, args...)
+			}
+
+			_, _, err := Load(args)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			assert.StringContains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestSecretFileConflict(t *testing.T) {
+	t.Setenv("SNIPPETBOX_DSN", "web:pass@/snippetbox")
+	t.Setenv("SNIPPETBOX_DSN_FILE", writeFile(t, "dsn", "web:pass@/snippetbox"))
+
+	_, _, err := Load(nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	assert.StringContains(t, err.Error(), "both SNIPPETBOX_DSN and SNIPPETBOX_DSN_FILE are set")
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.DB.DSN = "web:pass@/snippetbox"
+	cfg.Admin.Password = "s3cret"
+
+	var b strings.Builder
+	err := cfg.Print(&b)
+	assert.NilError(t, err)
+
+	out := b.String()
+	assert.Equal(t, strings.Contains(out, "pass@"), false)
+	assert.Equal(t, strings.Contains(out, "s3cret"), false)
+	assert.StringContains(t, out, `dsn = "[REDACTED]"`)
+	assert.StringContains(t, out, `dynamic = "60/m"`)
+}
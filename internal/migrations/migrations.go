@@ -0,0 +1,404 @@
+// Package migrations holds the database schema as ordered, versioned SQL
+// scripts, one directory per dialect, and applies them. Each migration is
+// a pair of files named like 0001_create_snippets.up.sql and
+// 0001_create_snippets.down.sql. Applied versions are recorded in the
+// schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/placeholder"
+)
+
+//go:embed "mysql" "sqlite" "postgres"
+var Files embed.FS
+
+// lockName identifies our migration lock, for databases with named locks.
+const lockName = "snippetbox_migrations"
+
+// lockTimeout is how long to wait for another migrator to finish.
+const lockTimeout = 60 * time.Second
+
+// Migration is one step in the evolution of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes a migration and whether it's been applied.
+type Status struct {
+	Migration
+	Applied time.Time // Zero if the migration hasn't been applied
+}
+
+var fileRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations for a dialect (mysql, sqlite or postgres)
+// from Files, in version order.
+func Load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(Files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("migrations: no migrations for %q", dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		matches := fileRX.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("migrations: unexpected file %s/%s", dialect, entry.Name())
+		}
+
+		version, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, err
+		}
+
+		script, err := fs.ReadFile(Files, dialect+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		}
+		if m.Name != matches[2] {
+			return nil, fmt.Errorf("migrations: version %d has two names, %s and %s", version, m.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	var migrations []Migration
+
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrations: version %d needs both an up and a down script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database. Only one Migrator, across
+// every process sharing the database, can run at a time; the others wait
+// for it to finish.
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    string
+	Migrations []Migration
+}
+
+// New returns a Migrator for the database with the dialect's migrations.
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, Dialect: dialect, Migrations: migrations}, nil
+}
+
+// Up applies every pending migration, oldest first, and returns those it
+// applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			stmt := m.rebind("INSERT INTO schema_migrations (version, name, applied) VALUES(?, ?, ?)")
+
+			err = m.run(ctx, conn, migration.Up, stmt, migration.Version, migration.Name, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("migrations: applying %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	// On SQLite a failure rolls back the whole run
+	if err != nil && m.Dialect == "sqlite" {
+		applied = nil
+	}
+
+	return applied, err
+}
+
+// Down rolls back the given number of applied migrations, newest first,
+// and returns those it rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var rolledBack []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+			migration := m.Migrations[i]
+
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+
+			stmt := m.rebind("DELETE FROM schema_migrations WHERE version = ?")
+
+			err = m.run(ctx, conn, migration.Down, stmt, migration.Version)
+			if err != nil {
+				return fmt.Errorf("migrations: rolling back %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+
+			rolledBack = append(rolledBack, migration)
+		}
+
+		return nil
+	})
+
+	if err != nil && m.Dialect == "sqlite" {
+		rolledBack = nil
+	}
+
+	return rolledBack, err
+}
+
+// Status lists every migration along with when, if ever, it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.Migrations {
+			statuses = append(statuses, Status{Migration: migration, Applied: done[migration.Version]})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Pending lists the migrations which haven't been applied yet. Unlike
+// Status, it doesn't take the migration lock, so it's cheap enough to call
+// from health checks; it fails if schema_migrations doesn't exist.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	done, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.Migrations {
+		if _, ok := done[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// withLock() runs fn on a single connection while holding the migration
+// lock, creating the schema_migrations table first if need be.
+//
+// MySQL and Postgres have named locks which last as long as the
+// connection holds them. SQLite doesn't, so there the whole run happens in
+// one write transaction instead, which also keeps out other writers; if
+// anything fails, everything done in the run is rolled back.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch m.Dialect {
+	case "mysql":
+		var locked sql.NullInt64
+
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&locked)
+		if err != nil {
+			return err
+		}
+		if locked.Int64 != 1 {
+			return errors.New("migrations: timed out waiting for another migration to finish")
+		}
+		defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+
+	case "postgres":
+		lockCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+		defer cancel()
+
+		_, err = conn.ExecContext(lockCtx, "SELECT pg_advisory_lock(hashtext($1))", lockName)
+		if err != nil {
+			return err
+		}
+		defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", lockName)
+
+	case "sqlite":
+		_, err = conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				conn.ExecContext(context.Background(), "ROLLBACK")
+				return
+			}
+			_, err = conn.ExecContext(ctx, "COMMIT")
+		}()
+
+	default:
+		return fmt.Errorf("migrations: unsupported dialect %q", m.Dialect)
+	}
+
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied TIMESTAMP NOT NULL
+	)`
+
+	_, err = conn.ExecContext(ctx, stmt)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// applied() returns when each applied migration was applied, by version.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := map[int]time.Time{}
+
+	for rows.Next() {
+		var version int
+		var applied time.Time
+
+		err = rows.Scan(&version, &applied)
+		if err != nil {
+			return nil, err
+		}
+		done[version] = applied
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return done, nil
+}
+
+// run() executes a migration script and then the statement recording it.
+// On Postgres, whose DDL is transactional, the two happen in one
+// transaction. MySQL commits each DDL statement as it goes, so a script
+// which fails part-way there must be cleaned up by hand.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	type execer interface {
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	}
+
+	var exec execer = conn
+	var tx *sql.Tx
+
+	if m.Dialect == "postgres" {
+		var err error
+
+		tx, err = conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		exec = tx
+	}
+
+	for _, stmt := range Statements(script) {
+		_, err := exec.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := exec.ExecContext(ctx, record, args...)
+	if err != nil {
+		return err
+	}
+
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// rebind() rewrites ? placeholders as $1, $2, etc. for Postgres.
+func (m *Migrator) rebind(query string) string {
+	if m.Dialect != "postgres" {
+		return query
+	}
+	return placeholder.Dollar(query)
+}
+
+// Statements splits a SQL script into its statements, as not every driver
+// can run several at once. Scripts are split on semicolons, so they mustn't
+// contain any within statements.
+func Statements(script string) []string {
+	var stmts []string
+
+	for _, stmt := range strings.Split(script, ";") {
+		stmt = strings.TrimSpace(stmt)
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	return stmts
+}
@@ -0,0 +1,97 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestLoad(t *testing.T) {
+	for _, dialect := range []string{"mysql", "sqlite", "postgres"} {
+		t.Run(dialect, func(t *testing.T) {
+			migrations, err := Load(dialect)
+			assert.NilError(t, err)
+
+			for i, m := range migrations {
+				assert.Equal(t, m.Version, i+1)
+			}
+		})
+	}
+
+	_, err := Load("oracle")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestLoadDialectsAgree(t *testing.T) {
+	want, err := Load("sqlite")
+	assert.NilError(t, err)
+
+	for _, dialect := range []string{"mysql", "postgres"} {
+		t.Run(dialect, func(t *testing.T) {
+			migrations, err := Load(dialect)
+			assert.NilError(t, err)
+			assert.Equal(t, len(migrations), len(want))
+
+			for i, m := range migrations {
+				assert.Equal(t, m.Name, want[i].Name)
+			}
+		})
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := New(db, "sqlite")
+	assert.NilError(t, err)
+
+	ctx := context.Background()
+
+	applied, err := m.Up(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), len(m.Migrations))
+
+	pending, err := m.Pending(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 0)
+
+	// Running again is a no-op
+	applied, err = m.Up(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(applied), 0)
+
+	rolledBack, err := m.Down(ctx, 2)
+	assert.NilError(t, err)
+	assert.Equal(t, len(rolledBack), 2)
+	assert.Equal(t, rolledBack[0].Version, len(m.Migrations))
+
+	statuses, err := m.Status(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(statuses), len(m.Migrations))
+
+	for i, s := range statuses {
+		assert.Equal(t, s.Applied.IsZero(), i >= len(m.Migrations)-2)
+	}
+
+	pending, err = m.Pending(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(pending), 2)
+
+	rolledBack, err = m.Down(ctx, len(m.Migrations))
+	assert.NilError(t, err)
+	assert.Equal(t, len(rolledBack), len(m.Migrations)-2)
+
+	var tables int
+	err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'").Scan(&tables)
+	assert.NilError(t, err)
+	assert.Equal(t, tables, 1) // Only schema_migrations remains
+}
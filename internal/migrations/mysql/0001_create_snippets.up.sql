@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME(6) NOT NULL,
+    expires DATETIME(6) NOT NULL,
+    INDEX idx_snippets_created (created)
+);
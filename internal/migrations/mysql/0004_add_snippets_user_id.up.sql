@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER AFTER id, ADD INDEX idx_snippets_user_id (user_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    token CHAR(43) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    created DATETIME(6) NOT NULL,
+    last_seen DATETIME(6) NOT NULL,
+    expires DATETIME(6) NOT NULL,
+    CONSTRAINT user_sessions_uc_token UNIQUE (token),
+    INDEX idx_user_sessions_user_id (user_id)
+);
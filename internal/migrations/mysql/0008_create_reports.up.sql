@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS reports (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    reporter_id INTEGER,
+    reporter_ip VARCHAR(45) NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    details TEXT NOT NULL,
+    created DATETIME(6) NOT NULL,
+    resolved BOOLEAN NOT NULL DEFAULT FALSE,
+    INDEX idx_reports_snippet_id (snippet_id)
+);
+
+CREATE TABLE IF NOT EXISTS moderation_decisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    moderator_id INTEGER,
+    decision VARCHAR(32) NOT NULL,
+    created DATETIME(6) NOT NULL
+);
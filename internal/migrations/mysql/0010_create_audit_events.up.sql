@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    type VARCHAR(64) NOT NULL,
+    actor_id INTEGER,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    details TEXT NOT NULL,
+    created DATETIME(6) NOT NULL,
+    INDEX idx_audit_events_created (created)
+);
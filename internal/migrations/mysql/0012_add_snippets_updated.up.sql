@@ -0,0 +1,5 @@
+ALTER TABLE snippets ADD COLUMN updated DATETIME(6) NULL AFTER expires;
+
+UPDATE snippets SET updated = created;
+
+ALTER TABLE snippets MODIFY updated DATETIME(6) NOT NULL;
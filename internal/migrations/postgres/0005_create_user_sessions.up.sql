@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    token CHAR(43) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    created TIMESTAMP NOT NULL,
+    last_seen TIMESTAMP NOT NULL,
+    expires TIMESTAMP NOT NULL,
+    CONSTRAINT user_sessions_uc_token UNIQUE (token)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions (user_id);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL,
+    reporter_id INTEGER,
+    reporter_ip VARCHAR(45) NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    details TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    resolved BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_snippet_id ON reports (snippet_id);
+
+CREATE TABLE IF NOT EXISTS moderation_decisions (
+    id SERIAL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL,
+    moderator_id INTEGER,
+    decision VARCHAR(32) NOT NULL,
+    created TIMESTAMP NOT NULL
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id SERIAL PRIMARY KEY,
+    type VARCHAR(64) NOT NULL,
+    actor_id INTEGER,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    details TEXT NOT NULL,
+    created TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created ON audit_events (created);
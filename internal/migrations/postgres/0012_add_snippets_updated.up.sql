@@ -0,0 +1,5 @@
+ALTER TABLE snippets ADD COLUMN updated TIMESTAMP;
+
+UPDATE snippets SET updated = created;
+
+ALTER TABLE snippets ALTER COLUMN updated SET NOT NULL;
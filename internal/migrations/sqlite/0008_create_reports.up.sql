@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL,
+    reporter_id INTEGER,
+    reporter_ip TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    resolved BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_snippet_id ON reports (snippet_id);
+
+CREATE TABLE IF NOT EXISTS moderation_decisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL,
+    moderator_id INTEGER,
+    decision TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
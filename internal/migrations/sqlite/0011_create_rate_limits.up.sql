@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS rate_limits (
+    bucket_key TEXT PRIMARY KEY,
+    tokens REAL NOT NULL,
+    updated DATETIME NOT NULL,
+    full_at DATETIME NOT NULL
+);
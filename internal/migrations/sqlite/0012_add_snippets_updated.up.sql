@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN updated DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+
+UPDATE snippets SET updated = created;
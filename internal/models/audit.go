@@ -0,0 +1,143 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// The types of security-relevant event that are recorded in the audit log.
+const (
+	EventSignup        = "user.signup"
+	EventLogin         = "user.login"
+	EventLoginFailed   = "user.login_failed"
+	EventLogout        = "user.logout"
+	EventAccountDelete = "user.account_delete"
+	EventSessionRevoke = "user.session_revoke"
+	EventSnippetCreate = "snippet.create"
+	EventSnippetDelete = "snippet.delete"
+	EventAdminAction   = "admin.action"
+	EventModeration    = "moderation.decision"
+)
+
+// AuditEventTypes lists every event type, for filtering the audit log.
+var AuditEventTypes = []string{
+	EventSignup, EventLogin, EventLoginFailed, EventLogout, EventAccountDelete,
+	EventSessionRevoke, EventSnippetCreate, EventSnippetDelete, EventAdminAction,
+	EventModeration,
+}
+
+// AuditEvent is an entry in the audit log. ActorID is zero when nobody was
+// logged in, e.g. for a failed login. Details holds a JSON object with
+// anything else worth knowing about the event.
+type AuditEvent struct {
+	ID        int
+	Type      string
+	ActorID   int
+	IP        string
+	UserAgent string
+	Details   string
+	Created   time.Time
+}
+
+// AuditFilter narrows down the events returned by AuditModel.List. Zero
+// values match everything.
+type AuditFilter struct {
+	Type    string
+	ActorID int
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// AuditStore is the set of methods the web application uses to write and
+// read the audit log.
+type AuditStore interface {
+	Insert(ctx context.Context, e AuditEvent) error
+	List(ctx context.Context, f AuditFilter) ([]AuditEvent, error)
+}
+
+// AuditModel wraps the audit_events table. The log is append-only, so
+// there are deliberately no methods to change or remove events.
+type AuditModel struct {
+	DB *DB
+}
+
+// Insert appends an event to the audit log.
+func (m *AuditModel) Insert(ctx context.Context, e AuditEvent) error {
+	ctx, span := m.DB.startSpan(ctx, "AuditModel.Insert")
+	defer span.End()
+
+	if e.Details == "" {
+		e.Details = "{}"
+	}
+
+	stmt := `INSERT INTO audit_events (type, actor_id, ip, user_agent, details, created)
+	VALUES(?, NULLIF(?, 0), ?, ?, ?, ?)`
+
+	_, err := m.DB.ExecContext(ctx, stmt, e.Type, e.ActorID, e.IP, truncate(e.UserAgent, maxUserAgentLength), e.Details, time.Now().UTC())
+	return err
+}
+
+// List returns the events matching the filter, newest first.
+func (m *AuditModel) List(ctx context.Context, f AuditFilter) ([]AuditEvent, error) {
+	ctx, span := m.DB.startSpan(ctx, "AuditModel.List")
+	defer span.End()
+
+	var where []string
+	var args []any
+
+	if f.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, f.Type)
+	}
+	if f.ActorID != 0 {
+		where = append(where, "actor_id = ?")
+		args = append(args, f.ActorID)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created >= ?")
+		args = append(args, f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created < ?")
+		args = append(args, f.Until.UTC())
+	}
+
+	stmt := `SELECT id, type, COALESCE(actor_id, 0), ip, user_agent, details, created
+	FROM audit_events`
+
+	if len(where) > 0 {
+		stmt += " WHERE " + strings.Join(where, " AND ")
+	}
+	stmt += " ORDER BY id DESC"
+
+	if f.Limit > 0 {
+		stmt += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+
+	for rows.Next() {
+		var e AuditEvent
+
+		err = rows.Scan(&e.ID, &e.Type, &e.ActorID, &e.IP, &e.UserAgent, &e.Details, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
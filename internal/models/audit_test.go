@@ -0,0 +1,24 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestAuditModelInsertLongUserAgent(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := AuditModel{DB: db}
+
+		userAgent := strings.Repeat("Mozilla/5.0 ", 100)
+
+		err := m.Insert(t.Context(), AuditEvent{Type: EventLogin, ActorID: 1, IP: "192.0.2.1", UserAgent: userAgent})
+		assert.NilError(t, err)
+
+		events, err := m.List(t.Context(), AuditFilter{})
+		assert.NilError(t, err)
+		assert.Equal(t, len(events), 1)
+		assert.Equal(t, events[0].UserAgent, userAgent[:maxUserAgentLength])
+	})
+}
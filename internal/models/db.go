@@ -0,0 +1,301 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rhysmah/snippet-box/internal/placeholder"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Dialect identifies one of the SQL databases we can store data in.
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// ParseDialect checks a dialect name, such as the value of --db-driver.
+func ParseDialect(name string) (Dialect, error) {
+	switch d := Dialect(name); d {
+	case MySQL, SQLite, Postgres:
+		return d, nil
+	}
+	return "", fmt.Errorf("models: unsupported database driver %q", name)
+}
+
+// DriverName returns the name the dialect's database/sql driver is
+// registered under. The caller must import the driver itself.
+func (d Dialect) DriverName() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	case Postgres:
+		return "pgx"
+	}
+	return "mysql"
+}
+
+// DB wraps a connection pool along with the dialect it speaks. Statements
+// passed to its methods are always written with ? placeholders, which are
+// rewritten to suit the dialect, so models can share the same SQL.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.DB.Exec(db.Dialect.rebind(query), args...)
+}
+
+func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.DB.Query(db.Dialect.rebind(query), args...)
+}
+
+func (db *DB) QueryRow(query string, args ...any) *sql.Row {
+	return db.DB.QueryRow(db.Dialect.rebind(query), args...)
+}
+
+// The Context versions of the methods also trace each statement, as a
+// child of the span in ctx; see startSpan().
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query = db.Dialect.rebind(query)
+
+	ctx, span := db.Dialect.startQuery(ctx, query)
+	defer span.End()
+
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return result, err
+}
+
+// QueryContext's span covers running the query, but not reading the rows.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query = db.Dialect.rebind(query)
+
+	ctx, span := db.Dialect.startQuery(ctx, query)
+	defer span.End()
+
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	query = db.Dialect.rebind(query)
+
+	ctx, span := db.Dialect.startQuery(ctx, query)
+	defer span.End()
+
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	recordError(span, row.Err())
+	return row
+}
+
+// Begin starts a transaction which, like DB, rewrites placeholders.
+func (db *DB) Begin() (*Tx, error) {
+	return db.BeginTx(context.Background(), nil)
+}
+
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, dialect: db.Dialect}, nil
+}
+
+// insertID() runs an INSERT statement and returns the ID of the new row.
+// Postgres doesn't support LastInsertId, so there we use RETURNING instead.
+func (db *DB) insertID(ctx context.Context, query string, args ...any) (int, error) {
+	if db.Dialect == Postgres {
+		var id int
+		err := db.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Tx is a transaction started by DB.Begin.
+type Tx struct {
+	*sql.Tx
+	dialect Dialect
+}
+
+func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRow(tx.dialect.rebind(query), args...)
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query = tx.dialect.rebind(query)
+
+	ctx, span := tx.dialect.startQuery(ctx, query)
+	defer span.End()
+
+	result, err := tx.Tx.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return result, err
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query = tx.dialect.rebind(query)
+
+	ctx, span := tx.dialect.startQuery(ctx, query)
+	defer span.End()
+
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	query = tx.dialect.rebind(query)
+
+	ctx, span := tx.dialect.startQuery(ctx, query)
+	defer span.End()
+
+	row := tx.Tx.QueryRowContext(ctx, query, args...)
+	recordError(span, row.Err())
+	return row
+}
+
+// tracer() returns the tracer for spans about the database. It's looked up
+// each time, so that it follows changes to the global TracerProvider; until
+// the application sets one, spans are discarded.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/rhysmah/snippet-box/internal/models")
+}
+
+// startSpan() starts a span for a model method, named like
+// "SnippetModel.Get". The statements it runs get spans of their own, as
+// its children.
+func (db *DB) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(db.Dialect.systemName()))
+}
+
+// startQuery() starts a span for a single statement, named after its
+// operation, e.g. "SELECT".
+func (d Dialect) startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	operation = strings.ToUpper(operation)
+
+	return tracer().Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			d.systemName(),
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(query),
+		))
+}
+
+// systemName() identifies the dialect in span attributes.
+func (d Dialect) systemName() attribute.KeyValue {
+	switch d {
+	case SQLite:
+		return semconv.DBSystemNameSQLite
+	case Postgres:
+		return semconv.DBSystemNamePostgreSQL
+	}
+	return semconv.DBSystemNameMySQL
+}
+
+// recordError() marks a span as failed, if err isn't nil.
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// rebind() rewrites ? placeholders as $1, $2, etc. for Postgres.
+func (d Dialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+	return placeholder.Dollar(query)
+}
+
+// insertOrIgnore() turns the rest of an INSERT statement, e.g.
+// "INTO t (a) VALUES(?)", into one which silently skips rows that would
+// break a unique constraint.
+func (d Dialect) insertOrIgnore(into string) string {
+	switch d {
+	case SQLite:
+		return "INSERT OR IGNORE " + into
+	case Postgres:
+		return "INSERT " + into + " ON CONFLICT DO NOTHING"
+	}
+	return "INSERT IGNORE " + into
+}
+
+// isUniqueViolation() reports whether err was caused by breaking the named
+// unique constraint. SQLite doesn't report constraint names, so for it we
+// look for the constrained column, as "table.column", instead.
+func isUniqueViolation(err error, constraint, column string) bool {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) {
+		return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, constraint)
+	}
+
+	var pgError *pgconn.PgError
+	if errors.As(err, &pgError) {
+		return pgError.Code == "23505" && pgError.ConstraintName == constraint
+	}
+
+	var sqliteError *sqlite.Error
+	if errors.As(err, &sqliteError) {
+		return sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteError.Error(), column)
+	}
+
+	return false
+}
+
+// truncate() shortens s to at most n characters, so it fits in a column of
+// that width; strict MySQL and Postgres reject longer values rather than
+// cutting them short. Invalid UTF-8, which Postgres also rejects, is
+// replaced too.
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTxQueries(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		spans := tracetest.NewSpanRecorder()
+
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+		t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+		tx, err := db.BeginTx(t.Context(), nil)
+		assert.NilError(t, err)
+		defer tx.Rollback()
+
+		// Placeholders are rewritten for each dialect, as they are outside
+		// a transaction
+		var title string
+		err = tx.QueryRowContext(t.Context(), "SELECT title FROM snippets WHERE id = ?", 1).Scan(&title)
+		assert.NilError(t, err)
+		assert.Equal(t, title, "An old silent pond")
+
+		rows, err := tx.QueryContext(t.Context(), "SELECT id FROM snippets WHERE id = ? OR id = ?", 1, 2)
+		assert.NilError(t, err)
+
+		var ids int
+		for rows.Next() {
+			ids++
+		}
+		assert.NilError(t, rows.Close())
+		assert.Equal(t, ids, 2)
+
+		ended := spans.Ended()
+		assert.Equal(t, len(ended), 2)
+		for _, span := range ended {
+			assert.Equal(t, span.Name(), "SELECT")
+		}
+	})
+}
@@ -1,6 +1,9 @@
 package models
 
-import "errors"
+import (
+	"database/sql"
+	"errors"
+)
 
 // Created so handlers aren't concerned with the underlying
 // datastore or reliant on datastore-specific errors.
@@ -8,4 +11,18 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrAccountDisabled    = errors.New("models: account disabled")
 )
+
+// checkRowsAffected() returns ErrNoRecord if a statement didn't change any
+// rows, i.e. the record it targeted doesn't exist.
+func checkRowsAffected(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
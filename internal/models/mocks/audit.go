@@ -0,0 +1,65 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+// AuditModel keeps the audit log in memory, so tests can check which
+// events were recorded.
+type AuditModel struct {
+	mu     sync.Mutex
+	events []models.AuditEvent
+}
+
+var _ models.AuditStore = (*AuditModel)(nil)
+
+func NewAuditModel() *AuditModel {
+	return &AuditModel{}
+}
+
+func (m *AuditModel) Insert(ctx context.Context, e models.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e.Details == "" {
+		e.Details = "{}"
+	}
+	e.ID = len(m.events) + 1
+	e.Created = time.Now().UTC()
+
+	m.events = append(m.events, e)
+	return nil
+}
+
+func (m *AuditModel) List(ctx context.Context, f models.AuditFilter) ([]models.AuditEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var events []models.AuditEvent
+
+	for i := len(m.events) - 1; i >= 0; i-- {
+		e := m.events[i]
+
+		switch {
+		case f.Type != "" && e.Type != f.Type:
+			continue
+		case f.ActorID != 0 && e.ActorID != f.ActorID:
+			continue
+		case !f.Since.IsZero() && e.Created.Before(f.Since):
+			continue
+		case !f.Until.IsZero() && !e.Created.Before(f.Until):
+			continue
+		}
+
+		events = append(events, e)
+		if f.Limit > 0 && len(events) == f.Limit {
+			break
+		}
+	}
+
+	return events, nil
+}
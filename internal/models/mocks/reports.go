@@ -0,0 +1,169 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+// ReportModel starts out with no reports. It only knows the titles and
+// authors of the snippets in its Snippets store, if that's set, so Queue
+// leaves them blank otherwise.
+type ReportModel struct {
+	Snippets *SnippetModel
+
+	mu        sync.Mutex
+	reports   []report
+	nextID    int
+	Decisions []Decision // Every decision recorded, oldest first
+}
+
+// report is a report along with whether it's been resolved.
+type report struct {
+	models.Report
+	resolved bool
+}
+
+// Decision is a moderation decision recorded by ReportModel.Decide.
+type Decision struct {
+	SnippetID   int
+	ModeratorID int
+	Decision    string
+}
+
+var _ models.ReportStore = (*ReportModel)(nil)
+
+func NewReportModel(snippets *SnippetModel) *ReportModel {
+	return &ReportModel{Snippets: snippets, nextID: 1}
+}
+
+func (m *ReportModel) Insert(ctx context.Context, snippetID, reporterID int, reporterIP, reason, details string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reports = append(m.reports, report{Report: models.Report{
+		ID:         m.nextID,
+		SnippetID:  snippetID,
+		ReporterID: reporterID,
+		ReporterIP: reporterIP,
+		Reason:     reason,
+		Details:    details,
+		Created:    time.Now().UTC(),
+	}})
+	m.nextID++
+
+	return nil
+}
+
+func (m *ReportModel) RecentCount(ctx context.Context, reporterID int, reporterIP string, within time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since := time.Now().Add(-within)
+	count := 0
+
+	for _, r := range m.reports {
+		mine := r.ReporterID == reporterID && (reporterID != 0 || r.ReporterIP == reporterIP)
+		if mine && r.Created.After(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *ReportModel) OpenReporters(ctx context.Context, snippetID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := map[int]bool{}
+	ips := map[string]bool{}
+
+	for _, r := range m.reports {
+		if r.SnippetID != snippetID || r.resolved {
+			continue
+		}
+		if r.ReporterID != 0 {
+			users[r.ReporterID] = true
+		} else {
+			ips[r.ReporterIP] = true
+		}
+	}
+
+	return len(users) + len(ips), nil
+}
+
+func (m *ReportModel) Queue(ctx context.Context) ([]models.ReportedSnippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var queue []models.ReportedSnippet
+	index := map[int]int{}
+
+	for _, r := range m.reports {
+		if r.resolved {
+			continue
+		}
+
+		i, ok := index[r.SnippetID]
+		if !ok {
+			queue = append(queue, m.reportedSnippet(r.SnippetID))
+			i = len(queue) - 1
+			index[r.SnippetID] = i
+		}
+		queue[i].Reports = append(queue[i].Reports, r.Report)
+	}
+
+	return queue, nil
+}
+
+func (m *ReportModel) Decide(ctx context.Context, snippetID, moderatorID int, decision string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Decisions = append(m.Decisions, Decision{snippetID, moderatorID, decision})
+
+	if decision != models.DecisionAutoHide {
+		for i := range m.reports {
+			if m.reports[i].SnippetID == snippetID {
+				m.reports[i].resolved = true
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *ReportModel) LatestDecision(ctx context.Context, snippetID int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.Decisions) - 1; i >= 0; i-- {
+		if m.Decisions[i].SnippetID == snippetID {
+			return m.Decisions[i].Decision, nil
+		}
+	}
+
+	return "", nil
+}
+
+// reportedSnippet() fills in what's known about a reported snippet.
+func (m *ReportModel) reportedSnippet(id int) models.ReportedSnippet {
+	rs := models.ReportedSnippet{SnippetID: id}
+
+	if m.Snippets != nil {
+		m.Snippets.mu.Lock()
+		s, ok := m.Snippets.snippets[id]
+		m.Snippets.mu.Unlock()
+
+		if ok {
+			rs.Title = s.Title
+			rs.AuthorID = s.UserID
+			rs.Hidden = s.Hidden
+		}
+	}
+
+	return rs
+}
@@ -0,0 +1,97 @@
+package mocks
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+// UserSessionModel starts out empty; sessions are added as users log in.
+type UserSessionModel struct {
+	mu       sync.Mutex
+	sessions map[string]models.UserSession
+	nextID   int
+}
+
+var _ models.UserSessionStore = (*UserSessionModel)(nil)
+
+func NewUserSessionModel() *UserSessionModel {
+	return &UserSessionModel{sessions: map[string]models.UserSession{}, nextID: 1}
+}
+
+func (m *UserSessionModel) Insert(ctx context.Context, userID int, token, ip, userAgent string, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	m.sessions[token] = models.UserSession{
+		ID:        m.nextID,
+		UserID:    userID,
+		Token:     token,
+		IP:        ip,
+		UserAgent: userAgent,
+		Created:   now,
+		LastSeen:  now,
+		Expires:   expires.UTC(),
+	}
+	m.nextID++
+
+	return nil
+}
+
+func (m *UserSessionModel) Touch(ctx context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[token]
+	if ok {
+		s.LastSeen = time.Now().UTC()
+		m.sessions[token] = s
+	}
+	return nil
+}
+
+func (m *UserSessionModel) All(ctx context.Context, userID int) ([]models.UserSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []models.UserSession
+
+	for _, s := range m.sessions {
+		if s.UserID == userID && s.Expires.After(time.Now()) {
+			sessions = append(sessions, s)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID > sessions[j].ID })
+
+	return sessions, nil
+}
+
+func (m *UserSessionModel) Delete(ctx context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, token)
+	return nil
+}
+
+func (m *UserSessionModel) ActiveUsers(ctx context.Context, within time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	since := time.Now().Add(-within)
+	users := map[int]bool{}
+
+	for _, s := range m.sessions {
+		if s.LastSeen.After(since) {
+			users[s.UserID] = true
+		}
+	}
+
+	return len(users), nil
+}
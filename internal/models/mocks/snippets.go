@@ -0,0 +1,194 @@
+// Package mocks provides in-memory implementations of the stores in the
+// models package, for testing handlers without a database. Each
+// constructor returns a store seeded with the same fixtures every time.
+package mocks
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+// Fixture times are fixed so tests can rely on them, and snippets expire
+// far enough in the future that they never appear expired.
+var (
+	fixtureCreated = time.Date(2024, time.March, 17, 10, 15, 0, 0, time.UTC)
+	fixtureExpires = time.Date(2099, time.March, 17, 10, 15, 0, 0, time.UTC)
+)
+
+// MockSnippet is the snippet every SnippetModel starts with; it was
+// written by the user Alice.
+var MockSnippet = models.Snippet{
+	ID:      1,
+	UserID:  1,
+	Title:   "An old silent pond",
+	Content: "An old silent pond...",
+	Created: fixtureCreated,
+	Expires: fixtureExpires,
+	Updated: fixtureCreated,
+}
+
+type SnippetModel struct {
+	mu       sync.Mutex
+	snippets map[int]models.Snippet
+	nextID   int
+}
+
+var _ models.SnippetStore = (*SnippetModel)(nil)
+
+func NewSnippetModel() *SnippetModel {
+	return &SnippetModel{
+		snippets: map[int]models.Snippet{MockSnippet.ID: MockSnippet},
+		nextID:   MockSnippet.ID + 1,
+	}
+}
+
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title, content string, expires int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	s := models.Snippet{
+		ID:      m.nextID,
+		UserID:  userID,
+		Title:   title,
+		Content: content,
+		Created: now,
+		Expires: now.AddDate(0, 0, expires),
+		Updated: now,
+	}
+	m.snippets[s.ID] = s
+	m.nextID++
+
+	return s.ID, nil
+}
+
+func (m *SnippetModel) Get(ctx context.Context, id int) (models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok || s.Hidden || !s.Expires.After(time.Now()) {
+		return models.Snippet{}, models.ErrNoRecord
+	}
+	return s, nil
+}
+
+func (m *SnippetModel) Latest(ctx context.Context) ([]models.Snippet, error) {
+	var snippets []models.Snippet
+
+	for _, s := range m.sorted(true) {
+		if s.Hidden || !s.Expires.After(time.Now()) {
+			continue
+		}
+		snippets = append(snippets, s)
+		if len(snippets) == 10 {
+			break
+		}
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) ForUser(ctx context.Context, userID int) ([]models.Snippet, error) {
+	var snippets []models.Snippet
+
+	for _, s := range m.sorted(false) {
+		if s.UserID == userID {
+			snippets = append(snippets, s)
+		}
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) All(ctx context.Context, limit, offset int) ([]models.Snippet, error) {
+	snippets := m.sorted(true)
+
+	if offset >= len(snippets) {
+		return nil, nil
+	}
+	snippets = snippets[offset:]
+
+	if limit < len(snippets) {
+		snippets = snippets[:limit]
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) SetHidden(ctx context.Context, id int, hidden bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if ok {
+		s.Hidden = hidden
+		s.Updated = time.Now().UTC()
+		m.snippets[id] = s
+	}
+	return nil
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.snippets[id]; !ok {
+		return models.ErrNoRecord
+	}
+	delete(m.snippets, id)
+
+	return nil
+}
+
+func (m *SnippetModel) Count(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.snippets), nil
+}
+
+func (m *SnippetModel) PerDay(ctx context.Context, days int) ([]models.DayCount, error) {
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -days)
+
+	var counts []models.DayCount
+
+	for _, s := range m.sorted(false) {
+		if s.Created.Before(since) {
+			continue
+		}
+
+		day := s.Created.UTC().Truncate(24 * time.Hour)
+		if len(counts) == 0 || !counts[len(counts)-1].Day.Equal(day) {
+			counts = append(counts, models.DayCount{Day: day})
+		}
+		counts[len(counts)-1].Count++
+	}
+
+	return counts, nil
+}
+
+// sorted() returns a copy of every snippet, ordered by ID.
+func (m *SnippetModel) sorted(newestFirst bool) []models.Snippet {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippets := make([]models.Snippet, 0, len(m.snippets))
+	for _, s := range m.snippets {
+		snippets = append(snippets, s)
+	}
+
+	sort.Slice(snippets, func(i, j int) bool {
+		if newestFirst {
+			return snippets[i].ID > snippets[j].ID
+		}
+		return snippets[i].ID < snippets[j].ID
+	})
+
+	return snippets
+}
@@ -0,0 +1,237 @@
+package mocks
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rhysmah/snippet-box/internal/models"
+)
+
+// MockPassword is the password of every user in MockUsers.
+const MockPassword = "pa$$word"
+
+// MockUsers are the users every UserModel starts with. Alice is an ordinary
+// user, Bob a moderator, Carol an admin and Dave has had his account disabled.
+var MockUsers = []models.User{
+	{ID: 1, Name: "Alice", Email: "alice@example.com", Created: fixtureCreated},
+	{ID: 2, Name: "Bob", Email: "bob@example.com", Created: fixtureCreated, Roles: []models.Role{models.RoleModerator}},
+	{ID: 3, Name: "Carol", Email: "carol@example.com", Created: fixtureCreated, Roles: []models.Role{models.RoleAdmin}},
+	{ID: 4, Name: "Dave", Email: "dave@example.com", Created: fixtureCreated, Disabled: true},
+}
+
+// mockUser is a user along with their plain-text password; there's no
+// point paying for bcrypt in tests.
+type mockUser struct {
+	models.User
+	password string
+}
+
+type UserModel struct {
+	mu     sync.Mutex
+	users  map[int]*mockUser
+	nextID int
+}
+
+var _ models.UserStore = (*UserModel)(nil)
+
+func NewUserModel() *UserModel {
+	m := &UserModel{users: map[int]*mockUser{}}
+
+	for _, u := range MockUsers {
+		u.Roles = slices.Clone(u.Roles)
+		m.users[u.ID] = &mockUser{User: u, password: MockPassword}
+		m.nextID = max(m.nextID, u.ID+1)
+	}
+
+	return m
+}
+
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.byEmail(email) != nil {
+		return models.ErrDuplicateEmail
+	}
+
+	m.users[m.nextID] = &mockUser{
+		User:     models.User{ID: m.nextID, Name: name, Email: email, Created: fixtureCreated},
+		password: password,
+	}
+	m.nextID++
+
+	return nil
+}
+
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := m.byEmail(email)
+	if u == nil || u.password != password {
+		return 0, models.ErrInvalidCredentials
+	}
+	if u.Disabled {
+		return 0, models.ErrAccountDisabled
+	}
+
+	return u.ID, nil
+}
+
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	return ok && !u.Disabled, nil
+}
+
+func (m *UserModel) Get(ctx context.Context, id int) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return models.User{}, models.ErrNoRecord
+	}
+
+	user := u.User
+	user.Roles = slices.Clone(u.Roles)
+
+	return user, nil
+}
+
+func (m *UserModel) CheckPassword(ctx context.Context, id int, password string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok || u.password != password {
+		return models.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// Delete removes the user. The mock doesn't know about other stores, so
+// the user's snippets are left alone whatever keepSnippets is.
+func (m *UserModel) Delete(ctx context.Context, id int, keepSnippets bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return models.ErrNoRecord
+	}
+	delete(m.users, id)
+
+	return nil
+}
+
+func (m *UserModel) Roles(ctx context.Context, id int) ([]models.Role, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return nil, nil
+	}
+	return slices.Clone(u.Roles), nil
+}
+
+func (m *UserModel) AddRole(ctx context.Context, id int, role models.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if ok && !slices.Contains(u.Roles, role) {
+		u.Roles = append(u.Roles, role)
+		slices.Sort(u.Roles)
+	}
+	return nil
+}
+
+func (m *UserModel) RemoveRole(ctx context.Context, id int, role models.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if ok {
+		u.Roles = slices.DeleteFunc(u.Roles, func(r models.Role) bool { return r == role })
+	}
+	return nil
+}
+
+func (m *UserModel) CountWithRole(ctx context.Context, role models.Role) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, u := range m.users {
+		if slices.Contains(u.Roles, role) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *UserModel) IDForEmail(ctx context.Context, email string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := m.byEmail(email)
+	if u == nil {
+		return 0, models.ErrNoRecord
+	}
+	return u.ID, nil
+}
+
+// List matches users like UserModel.List does, but always reports a
+// snippet count of zero.
+func (m *UserModel) List(ctx context.Context, search string) ([]models.UserSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	search = strings.ToLower(search)
+
+	var users []models.UserSummary
+
+	for _, u := range m.users {
+		if strings.Contains(strings.ToLower(u.Name), search) || strings.Contains(strings.ToLower(u.Email), search) {
+			users = append(users, models.UserSummary{User: u.User})
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID > users[j].ID })
+
+	return users, nil
+}
+
+func (m *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[id]
+	if ok {
+		u.Disabled = disabled
+	}
+	return nil
+}
+
+func (m *UserModel) Count(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.users), nil
+}
+
+// byEmail() finds a user by email address. The caller must hold m.mu.
+func (m *UserModel) byEmail(email string) *mockUser {
+	for _, u := range m.users {
+		if u.Email == email {
+			return u
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ReportReasons lists the reason categories a snippet can be reported for.
+var ReportReasons = []string{"spam", "abuse", "secrets", "illegal", "other"}
+
+// The decisions that can be recorded against a reported snippet.
+const (
+	DecisionDismiss  = "dismiss"
+	DecisionHide     = "hide"
+	DecisionBan      = "ban"
+	DecisionAutoHide = "auto-hide" // Made automatically, with no moderator
+)
+
+// Report is a single complaint about a snippet. ReporterID is zero if the
+// report was made anonymously.
+type Report struct {
+	ID         int
+	SnippetID  int
+	ReporterID int
+	ReporterIP string
+	Reason     string
+	Details    string
+	Created    time.Time
+}
+
+// ReportedSnippet gathers the open reports against one snippet, as shown in
+// the moderation queue.
+type ReportedSnippet struct {
+	SnippetID int
+	Title     string
+	AuthorID  int
+	Hidden    bool
+	Reports   []Report
+}
+
+// ReportStore is the set of methods the web application uses to work with
+// reports and moderation decisions.
+type ReportStore interface {
+	Insert(ctx context.Context, snippetID, reporterID int, reporterIP, reason, details string) error
+	RecentCount(ctx context.Context, reporterID int, reporterIP string, within time.Duration) (int, error)
+	OpenReporters(ctx context.Context, snippetID int) (int, error)
+	Queue(ctx context.Context) ([]ReportedSnippet, error)
+	Decide(ctx context.Context, snippetID, moderatorID int, decision string) error
+	LatestDecision(ctx context.Context, snippetID int) (string, error)
+}
+
+type ReportModel struct {
+	DB *DB
+}
+
+// Insert a new, open report against a snippet.
+func (m *ReportModel) Insert(ctx context.Context, snippetID, reporterID int, reporterIP, reason, details string) error {
+	ctx, span := m.DB.startSpan(ctx, "ReportModel.Insert")
+	defer span.End()
+
+	stmt := `INSERT INTO reports (snippet_id, reporter_id, reporter_ip, reason, details, created, resolved)
+	VALUES(?, NULLIF(?, 0), ?, ?, ?, ?, FALSE)`
+
+	_, err := m.DB.ExecContext(ctx, stmt, snippetID, reporterID, reporterIP, reason, details, time.Now().UTC())
+	return err
+}
+
+// RecentCount returns how many reports have been made within the given
+// period by a user or, for anonymous reporters (reporterID of zero), from
+// an IP address.
+func (m *ReportModel) RecentCount(ctx context.Context, reporterID int, reporterIP string, within time.Duration) (int, error) {
+	ctx, span := m.DB.startSpan(ctx, "ReportModel.RecentCount")
+	defer span.End()
+
+	var count int
+
+	stmt := `SELECT COUNT(*) FROM reports
+	WHERE (reporter_id = ? OR (? = 0 AND reporter_id IS NULL AND reporter_ip = ?))
+	AND created > ?`
+
+	since := time.Now().UTC().Add(-within)
+
+	err := m.DB.QueryRowContext(ctx, stmt, reporterID, reporterID, reporterIP, since).Scan(&count)
+	return count, err
+}
+
+// OpenReporters returns the number of independent reporters with open
+// reports against a snippet. Logged-in users are counted once each, and
+// anonymous reporters once per IP address.
+func (m *ReportModel) OpenReporters(ctx context.Context, snippetID int) (int, error) {
+	ctx, span := m.DB.startSpan(ctx, "ReportModel.OpenReporters")
+	defer span.End()
+
+	var count int
+
+	stmt := `SELECT COUNT(DISTINCT reporter_id) + COUNT(DISTINCT CASE WHEN reporter_id IS NULL THEN reporter_ip END)
+	FROM reports
+	WHERE snippet_id = ? AND NOT resolved`
+
+	err := m.DB.QueryRowContext(ctx, stmt, snippetID).Scan(&count)
+	return count, err
+}
+
+// Queue returns every snippet with open reports, oldest report first.
+func (m *ReportModel) Queue(ctx context.Context) ([]ReportedSnippet, error) {
+	ctx, span := m.DB.startSpan(ctx, "ReportModel.Queue")
+	defer span.End()
+
+	stmt := `SELECT r.id, r.snippet_id, COALESCE(r.reporter_id, 0), r.reporter_ip, r.reason, r.details, r.created,
+	s.title, COALESCE(s.user_id, 0), s.hidden
+	FROM reports r
+	JOIN snippets s ON s.id = r.snippet_id
+	WHERE NOT r.resolved
+	ORDER BY r.id`
+
+	rows, err := m.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queue []ReportedSnippet
+
+	// Remember where each snippet is in the queue, so that its reports are
+	// grouped together while keeping the queue in order of first report.
+	index := map[int]int{}
+
+	for rows.Next() {
+		var r Report
+		var s ReportedSnippet
+
+		err = rows.Scan(&r.ID, &r.SnippetID, &r.ReporterID, &r.ReporterIP, &r.Reason, &r.Details, &r.Created,
+			&s.Title, &s.AuthorID, &s.Hidden)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := index[r.SnippetID]
+		if !ok {
+			s.SnippetID = r.SnippetID
+			queue = append(queue, s)
+			i = len(queue) - 1
+			index[r.SnippetID] = i
+		}
+		queue[i].Reports = append(queue[i].Reports, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return queue, nil
+}
+
+// Decide records a decision about a reported snippet. Every decision other
+// than DecisionAutoHide (which leaves the snippet awaiting review) also
+// closes the snippet's open reports. moderatorID is zero for decisions
+// made automatically.
+func (m *ReportModel) Decide(ctx context.Context, snippetID, moderatorID int, decision string) error {
+	ctx, span := m.DB.startSpan(ctx, "ReportModel.Decide")
+	defer span.End()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO moderation_decisions (snippet_id, moderator_id, decision, created)
+	VALUES(?, NULLIF(?, 0), ?, ?)`
+
+	_, err = tx.ExecContext(ctx, stmt, snippetID, moderatorID, decision, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if decision != DecisionAutoHide {
+		_, err = tx.ExecContext(ctx, "UPDATE reports SET resolved = TRUE WHERE snippet_id = ? AND NOT resolved", snippetID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LatestDecision returns the most recent decision recorded about a snippet,
+// or an empty string if there hasn't been one.
+func (m *ReportModel) LatestDecision(ctx context.Context, snippetID int) (string, error) {
+	ctx, span := m.DB.startSpan(ctx, "ReportModel.LatestDecision")
+	defer span.End()
+
+	var decision string
+
+	stmt := `SELECT decision FROM moderation_decisions
+	WHERE snippet_id = ?
+	ORDER BY id DESC
+	LIMIT 1`
+
+	err := m.DB.QueryRowContext(ctx, stmt, snippetID).Scan(&decision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return decision, err
+}
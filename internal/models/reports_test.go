@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestReportModelLatestDecision(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := ReportModel{DB: db}
+
+		decision, err := m.LatestDecision(t.Context(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, decision, "")
+
+		for _, d := range []string{DecisionAutoHide, DecisionDismiss} {
+			err = m.Decide(t.Context(), 1, 0, d)
+			assert.NilError(t, err)
+
+			decision, err = m.LatestDecision(t.Context(), 1)
+			assert.NilError(t, err)
+			assert.Equal(t, decision, d)
+		}
+
+		// Decisions about other snippets don't count
+		decision, err = m.LatestDecision(t.Context(), 2)
+		assert.NilError(t, err)
+		assert.Equal(t, decision, "")
+	})
+}
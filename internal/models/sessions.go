@@ -0,0 +1,140 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// UserSession holds the metadata we record about a logged-in session.
+// Token is the scs session token, so it links each row to the
+// corresponding row in the session store's `sessions` table. Expires
+// mirrors the session's deadline in the store, so we never need to read
+// the store's table, whose layout differs between databases.
+type UserSession struct {
+	ID        int
+	UserID    int
+	Token     string
+	IP        string
+	UserAgent string
+	Created   time.Time
+	LastSeen  time.Time
+	Expires   time.Time
+}
+
+// UserSessionStore is the set of methods the web application uses to
+// record and list logged-in sessions.
+type UserSessionStore interface {
+	Insert(ctx context.Context, userID int, token, ip, userAgent string, expires time.Time) error
+	Touch(ctx context.Context, token string) error
+	All(ctx context.Context, userID int) ([]UserSession, error)
+	Delete(ctx context.Context, token string) error
+	ActiveUsers(ctx context.Context, within time.Duration) (int, error)
+}
+
+// maxUserAgentLength is the width of the user_agent columns of the
+// user_sessions and audit_events tables. Longer User-Agent headers are cut
+// short to fit.
+const maxUserAgentLength = 255
+
+type UserSessionModel struct {
+	DB *DB
+}
+
+// Insert records a new session for the user, which expires at the given
+// time. Rows left behind by sessions which have since expired are cleared
+// out first.
+func (m *UserSessionModel) Insert(ctx context.Context, userID int, token, ip, userAgent string, expires time.Time) error {
+	ctx, span := m.DB.startSpan(ctx, "UserSessionModel.Insert")
+	defer span.End()
+
+	now := time.Now().UTC()
+
+	stmt := "DELETE FROM user_sessions WHERE user_id = ? AND expires <= ?"
+
+	_, err := m.DB.ExecContext(ctx, stmt, userID, now)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO user_sessions (user_id, token, ip, user_agent, created, last_seen, expires)
+	VALUES(?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = m.DB.ExecContext(ctx, stmt, userID, token, ip, truncate(userAgent, maxUserAgentLength), now, now, expires.UTC())
+	return err
+}
+
+// Touch updates the last-seen time of a session. To avoid a write on every
+// single request, the row is only updated once a minute at most.
+func (m *UserSessionModel) Touch(ctx context.Context, token string) error {
+	ctx, span := m.DB.startSpan(ctx, "UserSessionModel.Touch")
+	defer span.End()
+
+	now := time.Now().UTC()
+
+	stmt := "UPDATE user_sessions SET last_seen = ? WHERE token = ? AND last_seen < ?"
+
+	_, err := m.DB.ExecContext(ctx, stmt, now, token, now.Add(-time.Minute))
+	return err
+}
+
+// All returns the user's active (i.e., unexpired) sessions, most recently
+// used first.
+func (m *UserSessionModel) All(ctx context.Context, userID int) ([]UserSession, error) {
+	ctx, span := m.DB.startSpan(ctx, "UserSessionModel.All")
+	defer span.End()
+
+	stmt := `SELECT id, user_id, token, ip, user_agent, created, last_seen, expires
+	FROM user_sessions
+	WHERE user_id = ? AND expires > ?
+	ORDER BY last_seen DESC`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []UserSession
+
+	for rows.Next() {
+		var s UserSession
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.Token, &s.IP, &s.UserAgent, &s.Created, &s.LastSeen, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Delete removes the metadata for a session. It doesn't touch the session
+// store itself; callers are responsible for destroying the session there.
+func (m *UserSessionModel) Delete(ctx context.Context, token string) error {
+	ctx, span := m.DB.startSpan(ctx, "UserSessionModel.Delete")
+	defer span.End()
+
+	stmt := "DELETE FROM user_sessions WHERE token = ?"
+
+	_, err := m.DB.ExecContext(ctx, stmt, token)
+	return err
+}
+
+// ActiveUsers returns the number of distinct users who've been seen within
+// the given period.
+func (m *UserSessionModel) ActiveUsers(ctx context.Context, within time.Duration) (int, error) {
+	ctx, span := m.DB.startSpan(ctx, "UserSessionModel.ActiveUsers")
+	defer span.End()
+
+	var count int
+
+	stmt := "SELECT COUNT(DISTINCT user_id) FROM user_sessions WHERE last_seen > ?"
+
+	err := m.DB.QueryRowContext(ctx, stmt, time.Now().UTC().Add(-within)).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestUserSessionModelInsertLongUserAgent(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := UserSessionModel{DB: db}
+
+		// Longer than the user_agent column, which strict databases would
+		// otherwise refuse
+		userAgent := strings.Repeat("Mozilla/5.0 ", 100)
+
+		token := strings.Repeat("a", 43)
+		err := m.Insert(t.Context(), 1, token, "192.0.2.1", userAgent, time.Now().Add(time.Hour))
+		assert.NilError(t, err)
+
+		sessions, err := m.All(t.Context(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, len(sessions), 1)
+		assert.Equal(t, sessions[0].UserAgent, userAgent[:maxUserAgentLength])
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"Short", "abc", 5, "abc"},
+		{"Exact", "abcde", 5, "abcde"},
+		{"Long", "abcdefg", 5, "abcde"},
+		{"Multibyte", "ééééé", 3, "ééé"},
+		{"Invalid UTF-8", "ab\xffcd", 10, "ab�cd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.s, tt.n)
+			assert.Equal(t, got, tt.want)
+			assert.Equal(t, utf8.ValidString(got), true)
+		})
+	}
+}
@@ -1,58 +1,79 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"time"
 )
 
+// SnippetStore is the set of methods the web application uses to work
+// with snippets. SnippetModel implements it against the database; tests
+// can swap in the in-memory version from the mocks package.
+type SnippetStore interface {
+	Insert(ctx context.Context, userID int, title, content string, expires int) (int, error)
+	Get(ctx context.Context, id int) (Snippet, error)
+	Latest(ctx context.Context) ([]Snippet, error)
+	ForUser(ctx context.Context, userID int) ([]Snippet, error)
+	All(ctx context.Context, limit, offset int) ([]Snippet, error)
+	SetHidden(ctx context.Context, id int, hidden bool) error
+	Delete(ctx context.Context, id int) error
+	Count(ctx context.Context) (int, error)
+	PerDay(ctx context.Context, days int) ([]DayCount, error)
+}
+
 // Define a snippet type to hold the data for an individual snippet.
 // The fields correspond to the fields in the MySQL snippets table.
 type Snippet struct {
 	ID      int // Created automatically by DB
+	UserID  int // Zero if the author deleted their account but kept their snippets
 	Title   string
 	Content string
 	Created time.Time // Created automatically by DB
 	Expires time.Time
+	Updated time.Time // When the snippet last changed; the same as Created until then
+	Hidden  bool      // Hidden snippets are only visible in the admin area
+}
+
+// DayCount is the number of snippets created on a particular (UTC) day.
+type DayCount struct {
+	Day   time.Time
+	Count int
 }
 
-// Define a SnippetModel type which wraps an sql.DB connection pool
+// Define a SnippetModel type which wraps a DB connection pool
 type SnippetModel struct {
-	DB *sql.DB
+	DB *DB
 }
 
-// Insert a new snippet into the database.
-func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
+// Insert a new snippet, written by the given user, into the database.
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title, content string, expires int) (int, error) {
+	ctx, span := m.DB.startSpan(ctx, "SnippetModel.Insert")
+	defer span.End()
 
 	// The SQL statement we want to execute
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (user_id, title, content, created, expires, updated)
+	VALUES(?, ?, ?, ?, ?, ?)`
 
-	// Use `Exec()` for queries that do NOT return rows
-	result, err := m.DB.Exec(stmt, title, content, expires)
-	if err != nil {
-		return 0, err
-	}
+	// Work out the times here rather than in SQL, as every database
+	// has its own date functions. Always store times in UTC.
+	now := time.Now().UTC()
 
-	// Use the LastInsertID() method on the result to get the ID
+	// Use `insertID()` to run the statement and get the ID
 	// of our newly inserted record in the snippets table.
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, err
-	}
-
-	// Returned ID has the type int64; convert it to int type before returning.
-	return int(id), nil
+	return m.DB.insertID(ctx, stmt, userID, title, content, now, now.AddDate(0, 0, expires), now)
 }
 
 // Return a specific snippet based on id
-func (m *SnippetModel) Get(id int) (Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (Snippet, error) {
+	ctx, span := m.DB.startSpan(ctx, "SnippetModel.Get")
+	defer span.End()
 
 	// The SQL statement we want to execute
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	stmt := `SELECT id, COALESCE(user_id, 0), title, content, created, expires, updated FROM snippets
+	WHERE expires > ? AND NOT hidden AND id = ?`
 
-	row := m.DB.QueryRow(stmt, id)
+	row := m.DB.QueryRowContext(ctx, stmt, time.Now().UTC(), id)
 
 	// initialized a new Snippet struct
 	var s Snippet
@@ -62,7 +83,7 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 	// row.Scan are *pointers* to the place the data is copied into.
 	// Number of arguments must be exactly the same as the number of
 	// columns returned by the statement.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Updated)
 	if err != nil {
 
 		// If no rows are returned, then error is returned
@@ -76,14 +97,16 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 }
 
 // Return 10 most recent snippets
-func (m *SnippetModel) Latest() ([]Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context) ([]Snippet, error) {
+	ctx, span := m.DB.startSpan(ctx, "SnippetModel.Latest")
+	defer span.End()
 
-	stmt := `SELECT id, title, content, created, expires 
+	stmt := `SELECT id, COALESCE(user_id, 0), title, content, created, expires, updated
 	FROM snippets 
-	WHERE expires > UTC_TIMESTAMP() 
+	WHERE expires > ? AND NOT hidden
 	ORDER BY id DESC LIMIT 10`
 
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.QueryContext(ctx, stmt, time.Now().UTC())
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +128,7 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 	for rows.Next() {
 		var s Snippet
 
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Updated)
 		if err != nil {
 			return nil, err
 		}
@@ -123,3 +146,166 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 
 	return snippets, nil
 }
+
+// Return every snippet written by a user, including expired ones, oldest first
+func (m *SnippetModel) ForUser(ctx context.Context, userID int) ([]Snippet, error) {
+	ctx, span := m.DB.startSpan(ctx, "SnippetModel.ForUser")
+	defer span.End()
+
+	stmt := `SELECT id, user_id, title, content, created, expires, updated, hidden
+	FROM snippets
+	WHERE user_id = ?
+	ORDER BY id`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		var s Snippet
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Updated, &s.Hidden)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Return a page of every snippet, newest first, including hidden and
+// expired ones; for use in the admin area.
+func (m *SnippetModel) All(ctx context.Context, limit, offset int) ([]Snippet, error) {
+	ctx, span := m.DB.startSpan(ctx, "SnippetModel.All")
+	defer span.End()
+
+	stmt := `SELECT id, COALESCE(user_id, 0), title, content, created, expires, updated, hidden
+	FROM snippets
+	ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		var s Snippet
+
+		err = rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Updated, &s.Hidden)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Hide or unhide a snippet. Hidden snippets aren't returned by Get or Latest.
+func (m *SnippetModel) SetHidden(ctx context.Context, id int, hidden bool) error {
+	ctx, span := m.DB.startSpan(ctx, "SnippetModel.SetHidden")
+	defer span.End()
+
+	stmt := "UPDATE snippets SET hidden = ?, updated = ? WHERE id = ?"
+
+	_, err := m.DB.ExecContext(ctx, stmt, hidden, time.Now().UTC(), id)
+	return err
+}
+
+// Delete a snippet permanently
+func (m *SnippetModel) Delete(ctx context.Context, id int) error {
+	ctx, span := m.DB.startSpan(ctx, "SnippetModel.Delete")
+	defer span.End()
+
+	result, err := m.DB.ExecContext(ctx, "DELETE FROM snippets WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return checkRowsAffected(result)
+}
+
+// Count returns the total number of snippets, including hidden and expired ones.
+func (m *SnippetModel) Count(ctx context.Context) (int, error) {
+	ctx, span := m.DB.startSpan(ctx, "SnippetModel.Count")
+	defer span.End()
+
+	var count int
+
+	err := m.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM snippets").Scan(&count)
+	return count, err
+}
+
+// Return the number of snippets created on each of the last `days` days.
+// Days on which no snippets were created are left out.
+func (m *SnippetModel) PerDay(ctx context.Context, days int) ([]DayCount, error) {
+	ctx, span := m.DB.startSpan(ctx, "SnippetModel.PerDay")
+	defer span.End()
+
+	// Databases disagree on how to truncate a time to a date, so fetch
+	// the creation times and count them up by day here instead.
+	stmt := `SELECT created FROM snippets WHERE created >= ? ORDER BY created`
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -days)
+
+	rows, err := m.DB.QueryContext(ctx, stmt, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []DayCount
+
+	for rows.Next() {
+		var created time.Time
+
+		err = rows.Scan(&created)
+		if err != nil {
+			return nil, err
+		}
+
+		day := created.UTC().Truncate(24 * time.Hour)
+		if len(counts) == 0 || !counts[len(counts)-1].Day.Equal(day) {
+			counts = append(counts, DayCount{Day: day})
+		}
+		counts[len(counts)-1].Count++
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// deleteSnippetsForUser() removes all of a user's snippets as part of a
+// wider transaction, such as deleting their account.
+func deleteSnippetsForUser(ctx context.Context, tx *Tx, userID int) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM snippets WHERE user_id = ?", userID)
+	return err
+}
+
+// anonymizeSnippetsForUser() keeps a user's snippets but detaches them
+// from the user, as part of a wider transaction.
+func anonymizeSnippetsForUser(ctx context.Context, tx *Tx, userID int) error {
+	stmt := "UPDATE snippets SET user_id = NULL, updated = ? WHERE user_id = ?"
+
+	_, err := tx.ExecContext(ctx, stmt, time.Now().UTC(), userID)
+	return err
+}
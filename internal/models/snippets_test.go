@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSnippetModelGet(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := SnippetModel{DB: db}
+
+		tests := []struct {
+			name      string
+			id        int
+			wantTitle string
+			wantErr   error
+		}{
+			{"Valid ID", 1, "An old silent pond", nil},
+			{"Expired", 2, "", ErrNoRecord},
+			{"Hidden", 3, "", ErrNoRecord},
+			{"Non-existent ID", 4, "", ErrNoRecord},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				s, err := m.Get(t.Context(), tt.id)
+
+				assert.Equal(t, errors.Is(err, tt.wantErr), true)
+				assert.Equal(t, s.Title, tt.wantTitle)
+			})
+		}
+	})
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := SnippetModel{DB: db}
+
+		id, err := m.Insert(t.Context(), 1, "O snail", "O snail\nClimb Mount Fuji", 7)
+		assert.NilError(t, err)
+
+		snippets, err := m.Latest(t.Context())
+		assert.NilError(t, err)
+
+		// The expired and hidden snippets from the seed data are left
+		// out, and the newest snippet comes first
+		assert.Equal(t, len(snippets), 2)
+		assert.Equal(t, snippets[0].ID, id)
+		assert.Equal(t, snippets[1].ID, 1)
+	})
+}
+
+func TestSnippetModelInsert(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := SnippetModel{DB: db}
+
+		before := time.Now().UTC().Add(-time.Second)
+
+		id, err := m.Insert(t.Context(), 1, "O snail", "O snail\nClimb Mount Fuji", 7)
+		assert.NilError(t, err)
+		assert.Equal(t, id, 4)
+
+		s, err := m.Get(t.Context(), id)
+		assert.NilError(t, err)
+
+		assert.Equal(t, s.UserID, 1)
+		assert.Equal(t, s.Content, "O snail\nClimb Mount Fuji")
+		assert.Equal(t, s.Created.After(before), true)
+		assert.Equal(t, s.Expires.Sub(s.Created).Round(time.Hour), 7*24*time.Hour)
+		assert.Equal(t, s.Updated.Equal(s.Created), true)
+	})
+}
+
+func TestSnippetModelSetHidden(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := SnippetModel{DB: db}
+
+		// The snippet is hidden and unhidden again; it must look changed
+		// afterwards, so cached copies of it are refreshed
+		err := m.SetHidden(t.Context(), 1, true)
+		assert.NilError(t, err)
+
+		_, err = m.Get(t.Context(), 1)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+		err = m.SetHidden(t.Context(), 1, false)
+		assert.NilError(t, err)
+
+		s, err := m.Get(t.Context(), 1)
+		assert.NilError(t, err)
+		assert.Equal(t, s.Updated.After(s.Created), true)
+	})
+}
+
+func TestSnippetModelTracing(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		spans := tracetest.NewSpanRecorder()
+
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+		t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+		m := SnippetModel{DB: db}
+
+		ctx, parent := otel.Tracer("test").Start(t.Context(), "request")
+		_, err := m.Get(ctx, 1)
+		assert.NilError(t, err)
+		parent.End()
+
+		ended := spans.Ended()
+		assert.Equal(t, len(ended), 3)
+
+		// The query's span is a child of the method's, which is a child of
+		// the caller's
+		query, method := ended[0], ended[1]
+		assert.Equal(t, query.Name(), "SELECT")
+		assert.Equal(t, query.Parent().SpanID(), method.SpanContext().SpanID())
+		assert.Equal(t, method.Name(), "SnippetModel.Get")
+		assert.Equal(t, method.Parent().SpanID(), parent.SpanContext().SpanID())
+	})
+}
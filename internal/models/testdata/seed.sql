@@ -0,0 +1,45 @@
+INSERT INTO users (name, email, hashed_password, created, disabled) VALUES (
+    'Alice Jones',
+    'alice@example.com',
+    '$2a$12$cqnxW/D.gR7EbjeKYbq.DeAR93IcccAF.xFIZ9Ax73ZQR8RxeQh6K',
+    '2024-03-17 10:15:00',
+    FALSE
+);
+
+INSERT INTO users (name, email, hashed_password, created, disabled) VALUES (
+    'Dave Smith',
+    'dave@example.com',
+    '$2a$12$cqnxW/D.gR7EbjeKYbq.DeAR93IcccAF.xFIZ9Ax73ZQR8RxeQh6K',
+    '2024-03-17 10:15:00',
+    TRUE
+);
+
+INSERT INTO snippets (user_id, title, content, created, expires, updated, hidden) VALUES (
+    1,
+    'An old silent pond',
+    'An old silent pond...',
+    '2024-03-17 10:15:00',
+    '2099-03-17 10:15:00',
+    '2024-03-17 10:15:00',
+    FALSE
+);
+
+INSERT INTO snippets (user_id, title, content, created, expires, updated, hidden) VALUES (
+    1,
+    'Over the wintry forest',
+    'Over the wintry forest, winds howl in rage...',
+    '2024-03-17 10:15:00',
+    '2024-03-18 10:15:00',
+    '2024-03-17 10:15:00',
+    FALSE
+);
+
+INSERT INTO snippets (user_id, title, content, created, expires, updated, hidden) VALUES (
+    1,
+    'First autumn morning',
+    'First autumn morning, the mirror I stare into...',
+    '2024-03-17 10:15:00',
+    '2099-03-17 10:15:00',
+    '2024-03-17 10:15:00',
+    TRUE
+);
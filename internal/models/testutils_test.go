@@ -0,0 +1,100 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/migrations"
+
+	// The MySQL and SQLite drivers are already imported by db.go
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// testDSNs names the environment variable holding the test database's DSN
+// for each dialect. SQLite needs no server, so it always runs against a
+// fresh file in a temporary directory instead. MySQL DSNs need the
+// parseTime=true parameter.
+var testDSNs = map[Dialect]string{
+	MySQL:    "SNIPPETBOX_TEST_MYSQL_DSN",
+	Postgres: "SNIPPETBOX_TEST_POSTGRES_DSN",
+}
+
+// forEachDialect() runs a test once for each database we support, as a
+// subtest named after the dialect, with a freshly set up test database.
+// Dialects with no test DSN configured are skipped.
+func forEachDialect(t *testing.T, test func(t *testing.T, db *DB)) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	for _, dialect := range []Dialect{SQLite, MySQL, Postgres} {
+		t.Run(string(dialect), func(t *testing.T) {
+			var dsn string
+
+			if dialect == SQLite {
+				dsn = "file:" + filepath.Join(t.TempDir(), "test.db") + "?_time_format=sqlite"
+			} else {
+				dsn = os.Getenv(testDSNs[dialect])
+				if dsn == "" {
+					t.Skipf("%s not set", testDSNs[dialect])
+				}
+			}
+
+			test(t, newTestDB(t, dialect, dsn))
+		})
+	}
+}
+
+// newTestDB() connects to a test database, migrates it to the latest
+// schema and loads the seed data in testdata/seed.sql. Every migration is
+// rolled back again once the test has finished.
+func newTestDB(t *testing.T, dialect Dialect, dsn string) *DB {
+	conn, err := sql.Open(dialect.DriverName(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &DB{DB: conn, Dialect: dialect}
+
+	migrator, err := migrations.New(conn, string(dialect))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clear out anything left behind by an earlier run that failed part-way
+	_, err = migrator.Down(context.Background(), len(migrator.Migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = migrator.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed, err := os.ReadFile(filepath.Join("testdata", "seed.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stmt := range migrations.Statements(string(seed)) {
+		_, err = db.Exec(stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Cleanup(func() {
+		defer db.Close()
+
+		_, err := migrator.Down(context.Background(), len(migrator.Migrations))
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	return db
+}
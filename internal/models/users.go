@@ -1,49 +1,92 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// User struct that exactly mirrors the database
-// representation of a user.
+// Role names a set of permissions. Roles are stored as plain strings in
+// the user_roles table, so adding one only needs a new constant here.
+type Role string
+
+const (
+	// RoleUser is held implicitly by every user; it's never stored.
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// UserStore is the set of methods the web application uses to work with
+// users and their roles.
+type UserStore interface {
+	Insert(ctx context.Context, name, email, password string) error
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Exists(ctx context.Context, id int) (bool, error)
+	Get(ctx context.Context, id int) (User, error)
+	CheckPassword(ctx context.Context, id int, password string) error
+	Delete(ctx context.Context, id int, keepSnippets bool) error
+	Roles(ctx context.Context, id int) ([]Role, error)
+	AddRole(ctx context.Context, id int, role Role) error
+	RemoveRole(ctx context.Context, id int, role Role) error
+	CountWithRole(ctx context.Context, role Role) (int, error)
+	IDForEmail(ctx context.Context, email string) (int, error)
+	List(ctx context.Context, search string) ([]UserSummary, error)
+	SetDisabled(ctx context.Context, id int, disabled bool) error
+	Count(ctx context.Context) (int, error)
+}
+
+// User struct that mirrors the database representation of a user,
+// plus the roles they've been granted.
 type User struct {
 	ID             int
 	Name           string
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	Disabled       bool
+	Roles          []Role
+}
+
+// UserSummary is a user as listed in the admin area.
+type UserSummary struct {
+	User
+	SnippetCount int
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB         *DB
+	BcryptCost int // Cost of hashing new passwords; zero means 12
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.Insert")
+	defer span.End()
 
 	// Hash the plain-text password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	cost := m.BcryptCost
+	if cost == 0 {
+		cost = 12
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		return err
 	}
 
 	// Insert user credentials, including hashed password, into database
-	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES(?, ?, ?, ?)`
 
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	_, err = m.DB.ExecContext(ctx, stmt, name, email, string(hashedPassword), time.Now().UTC())
 
-	// Check if the error is a MySQL error
+	// Check if the error is because the email is already taken
 	if err != nil {
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
-			}
+		if isUniqueViolation(err, "users_uc_email", "users.email") {
+			return ErrDuplicateEmail
 		}
 		return err
 	}
@@ -52,19 +95,23 @@ func (m *UserModel) Insert(name, email, password string) error {
 }
 
 // Authenticate a user based on email and password; if the user exists, return ID
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.Authenticate")
+	defer span.End()
+
 	// Return ID and hashed password associated with given email
 	var id int
 	var hashedPassword []byte
+	var disabled bool
 
-	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
+	stmt := "SELECT id, hashed_password, disabled FROM users WHERE email = ?"
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRowContext(ctx, stmt, email).Scan(&id, &hashedPassword, &disabled)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, ErrInvalidCredentials
 		} else {
-			return 0, nil
+			return 0, err
 		}
 	}
 
@@ -78,16 +125,276 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 		}
 	}
 
+	// Only tell the user their account is disabled once they've proven it's theirs
+	if disabled {
+		return 0, ErrAccountDisabled
+	}
+
 	// Else, password is correct; return user ID
 	return id, nil
 }
 
-// Checks if user with specific ID exists
-func (m *UserModel) Exists(id int) (bool, error) {
+// Checks if user with specific ID exists (and hasn't been disabled)
+func (m *UserModel) Exists(ctx context.Context, id int) (bool, error) {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.Exists")
+	defer span.End()
+
 	var exists bool
 
-	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ? AND NOT disabled)"
 
-	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&exists)
 	return exists, err
 }
+
+// Get returns the user with the given ID
+func (m *UserModel) Get(ctx context.Context, id int) (User, error) {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.Get")
+	defer span.End()
+
+	var user User
+
+	stmt := "SELECT id, name, email, created, disabled FROM users WHERE id = ?"
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&user.ID, &user.Name, &user.Email, &user.Created, &user.Disabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrNoRecord
+		}
+		return User{}, err
+	}
+
+	user.Roles, err = m.Roles(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+// CheckPassword confirms a password belongs to the user with the given ID;
+// if it doesn't, ErrInvalidCredentials is returned.
+func (m *UserModel) CheckPassword(ctx context.Context, id int, password string) error {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.CheckPassword")
+	defer span.End()
+
+	var hashedPassword []byte
+
+	stmt := "SELECT hashed_password FROM users WHERE id = ?"
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrInvalidCredentials
+	}
+	return err
+}
+
+// Delete removes a user along with their roles. Their snippets are either
+// deleted or, if keepSnippets is true, kept but no longer attributed to them.
+// Everything happens in one transaction, so a failure leaves nothing half-done.
+// The user's sessions live in the session store, outside the transaction, so
+// revoking them is left to the caller once Delete has succeeded.
+func (m *UserModel) Delete(ctx context.Context, id int, keepSnippets bool) error {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.Delete")
+	defer span.End()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	if keepSnippets {
+		err = anonymizeSnippetsForUser(ctx, tx, id)
+	} else {
+		err = deleteSnippetsForUser(ctx, tx, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	err = checkRowsAffected(result)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Roles returns the roles explicitly granted to a user. RoleUser is
+// implied and so never included.
+func (m *UserModel) Roles(ctx context.Context, id int) ([]Role, error) {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.Roles")
+	defer span.End()
+
+	stmt := "SELECT role FROM user_roles WHERE user_id = ? ORDER BY role"
+
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+
+	for rows.Next() {
+		var role Role
+
+		err = rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// AddRole grants a role to a user; granting a role they already hold is a no-op.
+func (m *UserModel) AddRole(ctx context.Context, id int, role Role) error {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.AddRole")
+	defer span.End()
+
+	stmt := m.DB.Dialect.insertOrIgnore("INTO user_roles (user_id, role) VALUES(?, ?)")
+
+	_, err := m.DB.ExecContext(ctx, stmt, id, string(role))
+	return err
+}
+
+// RemoveRole revokes a role from a user.
+func (m *UserModel) RemoveRole(ctx context.Context, id int, role Role) error {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.RemoveRole")
+	defer span.End()
+
+	stmt := "DELETE FROM user_roles WHERE user_id = ? AND role = ?"
+
+	_, err := m.DB.ExecContext(ctx, stmt, id, string(role))
+	return err
+}
+
+// CountWithRole returns how many users have been granted a role.
+func (m *UserModel) CountWithRole(ctx context.Context, role Role) (int, error) {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.CountWithRole")
+	defer span.End()
+
+	var count int
+
+	stmt := "SELECT COUNT(*) FROM user_roles WHERE role = ?"
+
+	err := m.DB.QueryRowContext(ctx, stmt, string(role)).Scan(&count)
+	return count, err
+}
+
+// IDForEmail returns the ID of the user with the given email address.
+func (m *UserModel) IDForEmail(ctx context.Context, email string) (int, error) {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.IDForEmail")
+	defer span.End()
+
+	var id int
+
+	stmt := "SELECT id FROM users WHERE email = ?"
+
+	err := m.DB.QueryRowContext(ctx, stmt, email).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// List returns users whose name or email contains the search term (or every
+// user, if it's empty), newest first, along with how many snippets each has.
+func (m *UserModel) List(ctx context.Context, search string) ([]UserSummary, error) {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.List")
+	defer span.End()
+
+	stmt := `SELECT u.id, u.name, u.email, u.created, u.disabled, COUNT(s.id)
+	FROM users u
+	LEFT JOIN snippets s ON s.user_id = u.id
+	WHERE LOWER(u.name) LIKE ? ESCAPE '!' OR LOWER(u.email) LIKE ? ESCAPE '!'
+	GROUP BY u.id, u.name, u.email, u.created, u.disabled
+	ORDER BY u.id DESC`
+
+	pattern := "%" + escapeLike(strings.ToLower(search)) + "%"
+
+	rows, err := m.DB.QueryContext(ctx, stmt, pattern, pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []UserSummary
+
+	for rows.Next() {
+		var u UserSummary
+
+		err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Disabled, &u.SnippetCount)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// SetDisabled disables or re-enables a user's account. Disabled users can't
+// log in, and Exists reports them as missing.
+func (m *UserModel) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.SetDisabled")
+	defer span.End()
+
+	stmt := "UPDATE users SET disabled = ? WHERE id = ?"
+
+	_, err := m.DB.ExecContext(ctx, stmt, disabled, id)
+	return err
+}
+
+// Count returns the total number of users.
+func (m *UserModel) Count(ctx context.Context) (int, error) {
+	ctx, span := m.DB.startSpan(ctx, "UserModel.Count")
+	defer span.End()
+
+	var count int
+
+	err := m.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+	return count, err
+}
+
+// escapeLike() escapes the wildcard characters in a LIKE pattern, using
+// ! as the escape character; unlike backslash, it means the same thing in
+// every database's string literals.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestUserModelInsert(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := UserModel{DB: db}
+
+		err := m.Insert(t.Context(), "Erin Brown", "erin@example.com", "pa$$word")
+		assert.NilError(t, err)
+
+		tests := []struct {
+			name    string
+			email   string
+			wantErr error
+		}{
+			{"Duplicate of a seeded user", "alice@example.com", ErrDuplicateEmail},
+			{"Duplicate of a new user", "erin@example.com", ErrDuplicateEmail},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := m.Insert(t.Context(), "Someone Else", tt.email, "pa$$word")
+				assert.Equal(t, errors.Is(err, tt.wantErr), true)
+			})
+		}
+
+		count, err := m.Count(t.Context())
+		assert.NilError(t, err)
+		assert.Equal(t, count, 3)
+	})
+}
+
+func TestUserModelAuthenticate(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := UserModel{DB: db}
+
+		tests := []struct {
+			name     string
+			email    string
+			password string
+			wantID   int
+			wantErr  error
+		}{
+			{"Valid credentials", "alice@example.com", "pa$$word", 1, nil},
+			{"Wrong password", "alice@example.com", "wrongPa$$word", 0, ErrInvalidCredentials},
+			{"Unknown email", "nobody@example.com", "pa$$word", 0, ErrInvalidCredentials},
+			{"Disabled account", "dave@example.com", "pa$$word", 0, ErrAccountDisabled},
+			{"Disabled account, wrong password", "dave@example.com", "wrongPa$$word", 0, ErrInvalidCredentials},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				id, err := m.Authenticate(t.Context(), tt.email, tt.password)
+
+				assert.Equal(t, id, tt.wantID)
+				if tt.wantErr == nil {
+					assert.NilError(t, err)
+				} else {
+					assert.Equal(t, errors.Is(err, tt.wantErr), true)
+				}
+			})
+		}
+	})
+}
+
+func TestUserModelExists(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := UserModel{DB: db}
+
+		tests := []struct {
+			name   string
+			userID int
+			want   bool
+		}{
+			{"Valid ID", 1, true},
+			{"Disabled user", 2, false},
+			{"Zero ID", 0, false},
+			{"Non-existent ID", 3, false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				exists, err := m.Exists(t.Context(), tt.userID)
+
+				assert.Equal(t, exists, tt.want)
+				assert.NilError(t, err)
+			})
+		}
+	})
+}
+
+func TestUserModelDelete(t *testing.T) {
+	tests := []struct {
+		name         string
+		keepSnippets bool
+		wantSnippets int
+	}{
+		{"Delete snippets", false, 0},
+		{"Keep snippets", true, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachDialect(t, func(t *testing.T, db *DB) {
+				m := UserModel{DB: db}
+
+				err := m.AddRole(t.Context(), 1, RoleModerator)
+				assert.NilError(t, err)
+
+				err = m.Delete(t.Context(), 1, tt.keepSnippets)
+				assert.NilError(t, err)
+
+				exists, err := m.Exists(t.Context(), 1)
+				assert.NilError(t, err)
+				assert.Equal(t, exists, false)
+
+				var roles int
+				err = db.QueryRow("SELECT COUNT(*) FROM user_roles WHERE user_id = ?", 1).Scan(&roles)
+				assert.NilError(t, err)
+				assert.Equal(t, roles, 0)
+
+				// Kept snippets stay, but are no longer attributed to anyone
+				var snippets, attributed int
+				err = db.QueryRow("SELECT COUNT(*) FROM snippets").Scan(&snippets)
+				assert.NilError(t, err)
+				assert.Equal(t, snippets, tt.wantSnippets)
+
+				err = db.QueryRow("SELECT COUNT(*) FROM snippets WHERE user_id IS NOT NULL").Scan(&attributed)
+				assert.NilError(t, err)
+				assert.Equal(t, attributed, 0)
+
+				// Deleting a user who doesn't exist changes nothing
+				err = m.Delete(t.Context(), 1, tt.keepSnippets)
+				assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+				// Dave is untouched
+				count, err := m.Count(t.Context())
+				assert.NilError(t, err)
+				assert.Equal(t, count, 1)
+			})
+		})
+	}
+}
+
+func TestUserModelCountWithRole(t *testing.T) {
+	forEachDialect(t, func(t *testing.T, db *DB) {
+		m := UserModel{DB: db}
+
+		admins, err := m.CountWithRole(t.Context(), RoleAdmin)
+		assert.NilError(t, err)
+		assert.Equal(t, admins, 0)
+
+		// Granting a role twice still counts the user once
+		for range 2 {
+			err = m.AddRole(t.Context(), 1, RoleAdmin)
+			assert.NilError(t, err)
+		}
+
+		admins, err = m.CountWithRole(t.Context(), RoleAdmin)
+		assert.NilError(t, err)
+		assert.Equal(t, admins, 1)
+
+		moderators, err := m.CountWithRole(t.Context(), RoleModerator)
+		assert.NilError(t, err)
+		assert.Equal(t, moderators, 0)
+	})
+}
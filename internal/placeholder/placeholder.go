@@ -0,0 +1,26 @@
+// Package placeholder rewrites the ? placeholders in SQL statements for
+// databases, such as Postgres, which number them instead.
+package placeholder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dollar rewrites ? placeholders as $1, $2, etc. Our statements never
+// contain a literal ?, so there's no need to parse them.
+func Dollar(query string) string {
+	var b strings.Builder
+	n := 0
+
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,25 @@
+package placeholder
+
+import (
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestDollar(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM snippets WHERE id = ?", "SELECT * FROM snippets WHERE id = $1"},
+		{"INSERT INTO t (a, b, c) VALUES(?, ?, ?)", "INSERT INTO t (a, b, c) VALUES($1, $2, $3)"},
+		{"SELECT '€' WHERE a = ?", "SELECT '€' WHERE a = $1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, Dollar(tt.query), tt.want)
+		})
+	}
+}
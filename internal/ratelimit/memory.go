@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // When the bucket will have refilled, if left alone
+}
+
+// MemoryStore keeps buckets in memory, so limits only apply per instance
+// of the application.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	stop    chan struct{}
+}
+
+// NewMemoryStore returns a MemoryStore which, every cleanupInterval,
+// forgets about buckets that have been idle long enough to refill.
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	m := &MemoryStore{
+		buckets: make(map[string]*bucket),
+		stop:    make(chan struct{}),
+	}
+
+	go m.startCleanup(cleanupInterval)
+
+	return m
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+
+	tokens, allowed, retryAfter := take(b.tokens, b.updated, now, limit)
+	b.tokens = tokens
+	b.updated = now
+	b.full = fullAt(tokens, now, limit)
+
+	return allowed, retryAfter, nil
+}
+
+func (m *MemoryStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.cleanup(time.Now())
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// cleanup() forgets about buckets which are full again by now, as they're
+// no different to the fresh buckets that Take starts with.
+func (m *MemoryStore) cleanup(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// StopCleanup stops the background cleanup goroutine.
+func (m *MemoryStore) StopCleanup() {
+	close(m.stop)
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	m := NewMemoryStore(time.Minute)
+	t.Cleanup(m.StopCleanup)
+
+	limit := Limit{Rate: 1.0 / 60, Burst: 2}
+
+	for _, want := range []bool{true, true, false} {
+		allowed, retryAfter, err := m.Take(t.Context(), "a", limit)
+		assert.NilError(t, err)
+		assert.Equal(t, allowed, want)
+		assert.Equal(t, retryAfter > 0, !want)
+	}
+
+	// Each key has its own bucket
+	allowed, _, err := m.Take(t.Context(), "b", limit)
+	assert.NilError(t, err)
+	assert.Equal(t, allowed, true)
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	m := NewMemoryStore(time.Hour)
+	t.Cleanup(m.StopCleanup)
+
+	buckets := func() int {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return len(m.buckets)
+	}
+
+	// A limit which takes an hour to refill, much longer than the cleanup
+	// interval main() uses
+	limit, err := ParseLimit("100/h")
+	assert.NilError(t, err)
+
+	start := time.Now()
+	for range limit.Burst {
+		allowed, _, err := m.Take(t.Context(), "slow", limit)
+		assert.NilError(t, err)
+		assert.Equal(t, allowed, true)
+	}
+
+	// One that refills within a second
+	_, _, err = m.Take(t.Context(), "fast", Limit{Rate: 10, Burst: 5})
+	assert.NilError(t, err)
+
+	// Idle buckets are only forgotten once they're full again, so the
+	// empty bucket outlives a ten minute idle period
+	m.cleanup(start.Add(10 * time.Minute))
+	assert.Equal(t, buckets(), 1)
+
+	allowed, _, err := m.Take(t.Context(), "slow", limit)
+	assert.NilError(t, err)
+	assert.Equal(t, allowed, false)
+
+	m.cleanup(start.Add(time.Hour + time.Minute))
+	assert.Equal(t, buckets(), 0)
+
+	// So the client starts again with a full bucket
+	allowed, _, err = m.Take(t.Context(), "slow", limit)
+	assert.NilError(t, err)
+	assert.Equal(t, allowed, true)
+}
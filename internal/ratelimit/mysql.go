@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MySQLStore keeps buckets in the rate_limits table, so that every instance
+// of the application sharing the database also shares the same limits.
+type MySQLStore struct {
+	DB   *sql.DB
+	stop chan struct{}
+}
+
+// NewMySQLStore returns a MySQLStore which, every cleanupInterval, deletes
+// buckets that have been idle long enough to refill.
+func NewMySQLStore(db *sql.DB, cleanupInterval time.Duration) *MySQLStore {
+	m := &MySQLStore{
+		DB:   db,
+		stop: make(chan struct{}),
+	}
+
+	go m.startCleanup(cleanupInterval)
+
+	return m
+}
+
+func (m *MySQLStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	// Make sure the bucket exists, so the SELECT below can lock it
+	stmt := `INSERT IGNORE INTO rate_limits (bucket_key, tokens, updated, full_at)
+	VALUES(?, ?, UTC_TIMESTAMP(6), UTC_TIMESTAMP(6))`
+
+	_, err = tx.ExecContext(ctx, stmt, key, limit.Burst)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var tokens float64
+	var updated, now time.Time
+
+	stmt = `SELECT tokens, updated, UTC_TIMESTAMP(6) FROM rate_limits
+	WHERE bucket_key = ? FOR UPDATE`
+
+	err = tx.QueryRowContext(ctx, stmt, key).Scan(&tokens, &updated, &now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	// Use the database's clock rather than ours, so instances with skewed
+	// clocks still agree
+	tokens, allowed, retryAfter := take(tokens, updated, now, limit)
+
+	stmt = "UPDATE rate_limits SET tokens = ?, updated = ?, full_at = ? WHERE bucket_key = ?"
+
+	_, err = tx.ExecContext(ctx, stmt, tokens, now, fullAt(tokens, now, limit), key)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return allowed, retryAfter, tx.Commit()
+}
+
+func (m *MySQLStore) startCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// There's nobody to report an error to; if the delete fails,
+			// it'll be retried next time around.
+			m.cleanup(context.Background())
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// cleanup() deletes the buckets which are full again by now, as they're no
+// different to the fresh buckets that Take starts with.
+func (m *MySQLStore) cleanup(ctx context.Context) error {
+	_, err := m.DB.ExecContext(ctx, "DELETE FROM rate_limits WHERE full_at <= UTC_TIMESTAMP(6)")
+	return err
+}
+
+// StopCleanup stops the background cleanup goroutine.
+func (m *MySQLStore) StopCleanup() {
+	close(m.stop)
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"github.com/rhysmah/snippet-box/internal/migrations"
+)
+
+// newTestMySQLStore() returns a MySQLStore using the test database named by
+// SNIPPETBOX_TEST_MYSQL_DSN, which needs parseTime=true as it does for the
+// models tests, migrated to the latest
+// schema. The test is skipped if there's no test database.
+func newTestMySQLStore(t *testing.T) *MySQLStore {
+	if testing.Short() {
+		t.Skip("ratelimit: skipping integration test")
+	}
+
+	dsn := os.Getenv("SNIPPETBOX_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("SNIPPETBOX_TEST_MYSQL_DSN not set")
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	assert.NilError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.New(db, "mysql")
+	assert.NilError(t, err)
+
+	_, err = migrator.Up(t.Context())
+	assert.NilError(t, err)
+
+	_, err = db.Exec("DELETE FROM rate_limits")
+	assert.NilError(t, err)
+
+	m := NewMySQLStore(db, time.Hour)
+	t.Cleanup(m.StopCleanup)
+
+	return m
+}
+
+func TestMySQLStoreExpiry(t *testing.T) {
+	m := newTestMySQLStore(t)
+
+	buckets := func() int {
+		var n int
+		err := m.DB.QueryRow("SELECT COUNT(*) FROM rate_limits").Scan(&n)
+		assert.NilError(t, err)
+		return n
+	}
+
+	limit, err := ParseLimit("100/h")
+	assert.NilError(t, err)
+
+	for range limit.Burst {
+		allowed, _, err := m.Take(t.Context(), "slow", limit)
+		assert.NilError(t, err)
+		assert.Equal(t, allowed, true)
+	}
+
+	allowed, retryAfter, err := m.Take(t.Context(), "slow", limit)
+	assert.NilError(t, err)
+	assert.Equal(t, allowed, false)
+	assert.Equal(t, retryAfter > 0, true)
+
+	// Ten minutes on, the empty bucket still hasn't refilled, so cleaning
+	// up keeps it
+	_, err = m.DB.Exec("UPDATE rate_limits SET updated = DATE_SUB(updated, INTERVAL 10 MINUTE), full_at = DATE_SUB(full_at, INTERVAL 10 MINUTE)")
+	assert.NilError(t, err)
+
+	err = m.cleanup(t.Context())
+	assert.NilError(t, err)
+	assert.Equal(t, buckets(), 1)
+
+	// Once it would be full, it's deleted
+	_, err = m.DB.Exec("UPDATE rate_limits SET updated = DATE_SUB(updated, INTERVAL 1 HOUR), full_at = DATE_SUB(full_at, INTERVAL 1 HOUR)")
+	assert.NilError(t, err)
+
+	err = m.cleanup(t.Context())
+	assert.NilError(t, err)
+	assert.Equal(t, buckets(), 0)
+}
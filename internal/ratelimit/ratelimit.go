@@ -0,0 +1,123 @@
+// Package ratelimit implements token bucket rate limiting, with buckets
+// held in a pluggable Store.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit describes a token bucket: it holds up to Burst tokens and refills
+// at Rate tokens per second. Each request takes one token.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Off reports whether the limit is disabled, i.e. lets everything through.
+func (l Limit) Off() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// ParseLimit parses limits written as "<requests>/<s|m|h>", e.g. "60/m".
+// The bucket's burst is the number of requests, so "60/m" allows 60
+// requests at once, refilling at one a second. "off" disables the limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "off" {
+		return Limit{}, nil
+	}
+
+	n, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("ratelimit: invalid limit %q", s)
+	}
+
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 1 {
+		return Limit{}, fmt.Errorf("ratelimit: invalid limit %q", s)
+	}
+
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("ratelimit: invalid unit in limit %q", s)
+	}
+
+	return Limit{Rate: float64(requests) / period.Seconds(), Burst: requests}, nil
+}
+
+// String formats a limit the way ParseLimit reads it, using the largest
+// unit which gives a whole number of requests per period.
+func (l Limit) String() string {
+	if l.Off() {
+		return "off"
+	}
+
+	units := []struct {
+		name   string
+		period time.Duration
+	}{{"s", time.Second}, {"m", time.Minute}, {"h", time.Hour}}
+
+	for _, u := range units {
+		if math.Abs(l.Rate*u.period.Seconds()-float64(l.Burst)) < 1e-9 {
+			return fmt.Sprintf("%d/%s", l.Burst, u.name)
+		}
+	}
+
+	return fmt.Sprintf("%g/s (burst %d)", l.Rate, l.Burst)
+}
+
+// MarshalText and UnmarshalText let limits be used in config files and
+// with flag.TextVar.
+func (l Limit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Limit) UnmarshalText(text []byte) error {
+	limit, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = limit
+	return nil
+}
+
+// Store holds token buckets, identified by key. Take removes one token
+// from the bucket if it can; if not, it reports how long until a token
+// will be available. Implementations must be safe for concurrent use.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// take() applies the token bucket algorithm to a bucket holding `tokens`
+// tokens as of `updated`. It returns the tokens left in the bucket as of
+// now, whether a token could be taken and, if not, how long until one can.
+func take(tokens float64, updated, now time.Time, limit Limit) (float64, bool, time.Duration) {
+	elapsed := max(now.Sub(updated).Seconds(), 0)
+	tokens = min(tokens+elapsed*limit.Rate, float64(limit.Burst))
+
+	if tokens < 1 {
+		wait := (1 - tokens) / limit.Rate
+		return tokens, false, time.Duration(math.Ceil(wait * float64(time.Second)))
+	}
+
+	return tokens - 1, true, 0
+}
+
+// fullAt() returns when a bucket holding `tokens` tokens as of now will be
+// full again. Until then the bucket must be kept, as a fresh bucket would
+// start out full.
+func fullAt(tokens float64, now time.Time, limit Limit) time.Time {
+	wait := max(float64(limit.Burst)-tokens, 0) / limit.Rate
+	return now.Add(time.Duration(math.Ceil(wait * float64(time.Second))))
+}
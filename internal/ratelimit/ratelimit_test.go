@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestTake(t *testing.T) {
+	now := time.Date(2024, time.March, 17, 10, 15, 0, 0, time.UTC)
+	limit := Limit{Rate: 1, Burst: 5}
+
+	tests := []struct {
+		name           string
+		tokens         float64
+		updated        time.Time
+		wantTokens     float64
+		wantAllowed    bool
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:        "Full",
+			tokens:      5,
+			updated:     now,
+			wantTokens:  4,
+			wantAllowed: true,
+		},
+		{
+			name:        "Last token",
+			tokens:      1,
+			updated:     now,
+			wantTokens:  0,
+			wantAllowed: true,
+		},
+		{
+			name:           "Empty",
+			tokens:         0,
+			updated:        now,
+			wantTokens:     0,
+			wantRetryAfter: time.Second,
+		},
+		{
+			name:           "Partly refilled",
+			tokens:         0,
+			updated:        now.Add(-750 * time.Millisecond),
+			wantTokens:     0.75,
+			wantRetryAfter: 250 * time.Millisecond,
+		},
+		{
+			name:        "Refilled",
+			tokens:      0,
+			updated:     now.Add(-2 * time.Second),
+			wantTokens:  1,
+			wantAllowed: true,
+		},
+		{
+			name:        "Refill capped at burst",
+			tokens:      0,
+			updated:     now.Add(-time.Hour),
+			wantTokens:  4,
+			wantAllowed: true,
+		},
+		{
+			name:           "Clock went backwards",
+			tokens:         0.5,
+			updated:        now.Add(time.Minute),
+			wantTokens:     0.5,
+			wantRetryAfter: 500 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, allowed, retryAfter := take(tt.tokens, tt.updated, now, limit)
+			assert.Equal(t, tokens, tt.wantTokens)
+			assert.Equal(t, allowed, tt.wantAllowed)
+			assert.Equal(t, retryAfter, tt.wantRetryAfter)
+		})
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Limit
+		wantErr bool
+	}{
+		{s: "off", want: Limit{}},
+		{s: "10/s", want: Limit{Rate: 10, Burst: 10}},
+		{s: "60/m", want: Limit{Rate: 1, Burst: 60}},
+		{s: "3600/h", want: Limit{Rate: 1, Burst: 3600}},
+		{s: "", wantErr: true},
+		{s: "60", wantErr: true},
+		{s: "0/m", wantErr: true},
+		{s: "-1/m", wantErr: true},
+		{s: "x/m", wantErr: true},
+		{s: "60/d", wantErr: true},
+		{s: "60/", wantErr: true},
+		{s: "Off", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			limit, err := ParseLimit(tt.s)
+			if tt.wantErr {
+				assert.Equal(t, err != nil, true)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, limit, tt.want)
+		})
+	}
+}
+
+func TestLimitString(t *testing.T) {
+	tests := []struct {
+		limit Limit
+		want  string
+	}{
+		{Limit{}, "off"},
+		{Limit{Rate: 5, Burst: 0}, "off"},
+		{Limit{Rate: 10, Burst: 10}, "10/s"},
+		{Limit{Rate: 1, Burst: 60}, "60/m"},
+		{Limit{Rate: 5.0 / 3600, Burst: 5}, "5/h"},
+		{Limit{Rate: 2, Burst: 5}, "2/s (burst 5)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.limit.String(), tt.want)
+		})
+	}
+
+	// Limits which ParseLimit reads come back out the same
+	for _, s := range []string{"off", "1/s", "10/s", "30/m", "90/m", "5/h", "7200/h"} {
+		limit, err := ParseLimit(s)
+		assert.NilError(t, err)
+		assert.Equal(t, limit.String(), s)
+
+		var unmarshalled Limit
+		err = unmarshalled.UnmarshalText([]byte(s))
+		assert.NilError(t, err)
+		assert.Equal(t, unmarshalled, limit)
+	}
+}
+
+func TestFullAt(t *testing.T) {
+	now := time.Date(2024, time.March, 17, 10, 15, 0, 0, time.UTC)
+	limit := Limit{Rate: 2, Burst: 10}
+
+	tests := []struct {
+		tokens float64
+		want   time.Time
+	}{
+		{10, now},
+		{9, now.Add(500 * time.Millisecond)},
+		{0, now.Add(5 * time.Second)},
+		{0.5, now.Add(4750 * time.Millisecond)},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, fullAt(tt.tokens, now, limit), tt.want)
+	}
+}
@@ -0,0 +1,175 @@
+// Package realip works out the IP address of the client that made a
+// request, taking into account any trusted reverse proxies in front of
+// the application.
+package realip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// The forwarding headers a Resolver can read.
+const (
+	XForwardedFor = "xff"
+	Forwarded     = "forwarded"
+)
+
+// Resolver finds the client IP for requests. Forwarding headers are only
+// believed when they were added by one of the Trusted proxies; with no
+// trusted proxies, the connection's remote address is always used.
+//
+// Only the one header the proxies set is read: Header is XForwardedFor, the
+// default, or Forwarded. A proxy which only appends to one header passes
+// the other through as the client sent it, so reading both would let
+// clients choose their own address.
+type Resolver struct {
+	Trusted []netip.Prefix
+	Header  string
+}
+
+// ParseHeader checks that s names a forwarding header a Resolver can read.
+func ParseHeader(s string) (string, error) {
+	switch s {
+	case XForwardedFor, Forwarded:
+		return s, nil
+	}
+	return "", fmt.Errorf("realip: unknown forwarding header %q (want %s or %s)", s, XForwardedFor, Forwarded)
+}
+
+// ParseTrusted parses a comma-separated list of CIDRs, such as
+// "10.0.0.0/8,192.168.1.1". Bare IP addresses are treated as a single host.
+func ParseTrusted(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, fmt.Errorf("realip: invalid trusted proxy %q", field)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, fmt.Errorf("realip: invalid trusted proxy %q", field)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// ClientIP returns the client IP for a request. Starting from the peer that
+// connected to us, it walks back along the chain of proxies recorded in
+// the X-Forwarded-For or Forwarded (RFC 7239) header, whichever the
+// resolver reads, for as long as each hop is trusted. The first untrusted
+// hop is the client.
+func (rs *Resolver) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+
+	// IPv4 clients of a dual-stack listener connect from IPv4-mapped
+	// addresses, such as ::ffff:192.0.2.1, which we report as plain IPv4
+	peer = peer.Unmap()
+	if !rs.trusted(peer) {
+		return peer.String()
+	}
+
+	var hops []string
+	if rs.Header == Forwarded {
+		hops = forwardedFor(r.Header)
+	} else {
+		hops = xForwardedFor(r.Header)
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		// Whatever comes before a hop we can't parse can't be relied on,
+		// so stop at the last address we could trust.
+		addr, err := parseHop(hops[i])
+		if err != nil {
+			break
+		}
+
+		client = addr
+		if !rs.trusted(addr) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+func (rs *Resolver) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range rs.Trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor() returns the "for" parameter of each element of any
+// Forwarded headers, in order.
+func forwardedFor(h http.Header) []string {
+	var hops []string
+
+	for _, value := range h.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(val, `"`))
+				}
+			}
+		}
+	}
+
+	return hops
+}
+
+// xForwardedFor() returns the addresses in any X-Forwarded-For headers, in order.
+func xForwardedFor(h http.Header) []string {
+	var hops []string
+
+	for _, value := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	return hops
+}
+
+// parseHop() parses a forwarded address, which may have a port and, for
+// IPv6, brackets, e.g. "[2001:db8::1]:4711". RFC 7239's "unknown" and
+// obfuscated identifiers are rejected, as they aren't IP addresses.
+func parseHop(hop string) (netip.Addr, error) {
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(hop, "[]"))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
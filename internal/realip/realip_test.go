@@ -0,0 +1,279 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8:ffff::/48"),
+	}
+
+	tests := []struct {
+		name       string
+		trusted    []netip.Prefix
+		header     string
+		remoteAddr string
+		headers    http.Header
+		want       string
+	}{
+		{
+			name:       "No trusted proxies",
+			remoteAddr: "192.0.2.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"203.0.113.7"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "Untrusted peer",
+			trusted:    trusted,
+			remoteAddr: "192.0.2.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"203.0.113.7"}, "Forwarded": {"for=203.0.113.7"}},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "Untrusted IPv4-mapped peer",
+			remoteAddr: "[::ffff:192.0.2.1]:1234",
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "Untrusted IPv6 peer",
+			trusted:    trusted,
+			remoteAddr: "[2001:db8::1]:1234",
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "No port",
+			remoteAddr: "192.0.2.1",
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "Trusted peer without headers",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "Trusted IPv4-mapped peer",
+			trusted:    trusted,
+			remoteAddr: "[::ffff:10.0.0.1]:1234",
+			headers:    http.Header{"X-Forwarded-For": {"203.0.113.7"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "X-Forwarded-For",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"203.0.113.7"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "X-Forwarded-For through trusted proxies",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"203.0.113.7, 10.0.0.3, 10.0.0.2"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "X-Forwarded-For with spoofed leftmost entries",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"10.0.0.9, 198.51.100.1, 203.0.113.7"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "X-Forwarded-For across several headers",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"198.51.100.1, 203.0.113.7", "10.0.0.2"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "X-Forwarded-For from trusted proxies only",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "X-Forwarded-For with ports and IPv4-mapped addresses",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"::ffff:203.0.113.7, 10.0.0.2:8080"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "X-Forwarded-For with IPv6",
+			trusted:    trusted,
+			remoteAddr: "[2001:db8:ffff::1]:1234",
+			headers:    http.Header{"X-Forwarded-For": {"[2001:db8::7]:4711, 2001:db8:ffff::2"}},
+			want:       "2001:db8::7",
+		},
+		{
+			name:       "X-Forwarded-For with garbage",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"203.0.113.7, garbage, 10.0.0.2"}},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "Forwarded",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"Forwarded": {"for=203.0.113.7;proto=https;by=10.0.0.1"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "Forwarded from the client when reading X-Forwarded-For",
+			trusted:    trusted,
+			header:     XForwardedFor,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"Forwarded": {"for=198.51.100.1"}, "X-Forwarded-For": {"203.0.113.7"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "X-Forwarded-For from the client when reading Forwarded",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"Forwarded": {"for=203.0.113.7"}, "X-Forwarded-For": {"198.51.100.1"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "No Forwarded header when reading Forwarded",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"X-Forwarded-For": {"198.51.100.1"}},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "Forwarded with spoofed leftmost entries",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"Forwarded": {"for=10.0.0.9, for=198.51.100.1", "For=203.0.113.7, for=10.0.0.2"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "Forwarded with quoted IPv6 and port",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"Forwarded": {`for="[2001:db8:cafe::17]:4711"`}},
+			want:       "2001:db8:cafe::17",
+		},
+		{
+			name:       "Forwarded with quoted IPv4",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"Forwarded": {`for="203.0.113.7:4711";proto=https`}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "Forwarded with unknown hop",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"Forwarded": {"for=203.0.113.7, for=unknown, for=10.0.0.2"}},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "Forwarded with obfuscated hop",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"Forwarded": {`for=203.0.113.7, for="_hidden"`}},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "Forwarded without for",
+			trusted:    trusted,
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    http.Header{"Forwarded": {"proto=https"}},
+			want:       "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for key, values := range tt.headers {
+				for _, value := range values {
+					r.Header.Add(key, value)
+				}
+			}
+
+			rs := &Resolver{Trusted: tt.trusted, Header: tt.header}
+			assert.Equal(t, rs.ClientIP(r), tt.want)
+		})
+	}
+}
+
+func TestParseTrusted(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []netip.Prefix
+		wantErr bool
+	}{
+		{
+			name: "Empty",
+			s:    "",
+		},
+		{
+			name: "CIDRs and addresses",
+			s:    "10.1.2.3/8, 192.168.1.1,,2001:db8::/32",
+			want: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.0/8"),
+				netip.MustParsePrefix("192.168.1.1/32"),
+				netip.MustParsePrefix("2001:db8::/32"),
+			},
+		},
+		{
+			name:    "Invalid address",
+			s:       "10.0.0.256",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid CIDR",
+			s:       "10.0.0.0/33",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := ParseTrusted(tt.s)
+			if tt.wantErr {
+				assert.Equal(t, err != nil, true)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, len(prefixes), len(tt.want))
+			for i := range prefixes {
+				assert.Equal(t, prefixes[i], tt.want[i])
+			}
+		})
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	for _, s := range []string{XForwardedFor, Forwarded} {
+		header, err := ParseHeader(s)
+		assert.NilError(t, err)
+		assert.Equal(t, header, s)
+	}
+
+	_, err := ParseHeader("x-real-ip")
+	assert.Equal(t, err != nil, true)
+}
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"math"
+	"slices"
+	"strings"
+)
+
+// EntropyDetector reports long, random-looking strings, which catches
+// secrets that don't have a recognisable format. Randomness is measured as
+// Shannon entropy, in bits per character.
+type EntropyDetector struct {
+	MinLength  int     // Ignore strings shorter than this
+	MinEntropy float64 // Report strings with at least this much entropy
+}
+
+// DefaultEntropyDetector is tuned to ignore hex-encoded hashes and UUIDs,
+// which have at most 4 bits of entropy per character, while catching
+// base64-style keys and tokens.
+var DefaultEntropyDetector = EntropyDetector{MinLength: 32, MinEntropy: 4.5}
+
+// Detect skips the lines of PEM-encoded private keys, which PEMDetector
+// reports once, rather than reporting every line of the key.
+func (d EntropyDetector) Detect(content string) []Finding {
+	var findings []Finding
+
+	blocks := pemBlocks(content)
+	inPEMBlock := func(line int) bool {
+		return slices.ContainsFunc(blocks, func(b [2]int) bool {
+			return line >= b[0] && line <= b[1]
+		})
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		if inPEMBlock(i + 1) {
+			continue
+		}
+
+		for _, word := range strings.FieldsFunc(line, isNotTokenChar) {
+			if len(word) >= d.MinLength && entropy(word) >= d.MinEntropy {
+				findings = append(findings, Finding{Rule: "high-entropy string", Line: i + 1})
+			}
+		}
+	}
+
+	return findings
+}
+
+// isNotTokenChar() reports whether r can't appear in a base64 (standard or
+// URL-safe) encoded string, and so separates candidate secrets.
+func isNotTokenChar(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	case r == '+', r == '/', r == '=', r == '-', r == '_':
+		return false
+	}
+	return true
+}
+
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+
+	var e float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
@@ -0,0 +1,158 @@
+// Package secrets looks for credentials, such as API keys and private keys,
+// that have been pasted into a piece of text.
+package secrets
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Finding describes a suspected secret: the rule that found it and the
+// (1-based) line it's on.
+type Finding struct {
+	Rule string
+	Line int
+}
+
+// Detector is implemented by anything that can look for secrets in text.
+type Detector interface {
+	Detect(content string) []Finding
+}
+
+// Scanner runs a set of detectors over some text.
+type Scanner struct {
+	Detectors []Detector
+}
+
+// Scan returns every finding from every detector, ordered by line, with
+// repeated findings of the same rule on the same line dropped.
+func (s *Scanner) Scan(content string) []Finding {
+	var findings []Finding
+
+	for _, d := range s.Detectors {
+		findings = append(findings, d.Detect(content)...)
+	}
+
+	slices.SortFunc(findings, func(a, b Finding) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Rule, b.Rule))
+	})
+
+	return slices.Compact(findings)
+}
+
+// Rule is a named regular expression that matches a kind of secret. Rules
+// can be loaded from a file with LoadRules.
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// DefaultRules match the formats of some commonly leaked credentials.
+var DefaultRules = []Rule{
+	{Name: "AWS access key ID", Pattern: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "AWS secret access key", Pattern: `(?i)aws.{0,20}secret.{0,20}[:=]\s*['"]?[0-9a-zA-Z/+]{40}\b`},
+	{Name: "GitHub token", Pattern: `\bgh[pousr]_[0-9a-zA-Z]{36,}\b`},
+	{Name: "Slack token", Pattern: `\bxox[abposr]-[0-9a-zA-Z-]{10,}`},
+	{Name: "Google API key", Pattern: `\bAIza[0-9A-Za-z_-]{35}\b`},
+	{Name: "Stripe secret key", Pattern: `\b[rs]k_live_[0-9a-zA-Z]{24,}\b`},
+}
+
+// LoadRules reads a JSON file holding a list of rules, such as:
+//
+//	[{"name": "Internal API token", "pattern": "\\bint_[0-9a-f]{32}\\b"}]
+func LoadRules(path string) ([]Rule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+
+	err = json.Unmarshal(b, &rules)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: parsing %s: %w", path, err)
+	}
+
+	return rules, nil
+}
+
+// RegexDetector reports every match of a set of rules.
+type RegexDetector struct {
+	names    []string
+	patterns []*regexp.Regexp
+}
+
+// NewRegexDetector compiles the given rules, returning an error if any of
+// them are invalid.
+func NewRegexDetector(rules []Rule) (*RegexDetector, error) {
+	d := &RegexDetector{}
+
+	for _, rule := range rules {
+		rx, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: rule %q: %w", rule.Name, err)
+		}
+
+		d.names = append(d.names, rule.Name)
+		d.patterns = append(d.patterns, rx)
+	}
+
+	return d, nil
+}
+
+func (d *RegexDetector) Detect(content string) []Finding {
+	var findings []Finding
+
+	for i, rx := range d.patterns {
+		for _, loc := range rx.FindAllStringIndex(content, -1) {
+			findings = append(findings, Finding{Rule: d.names[i], Line: lineAt(content, loc[0])})
+		}
+	}
+
+	return findings
+}
+
+var pemRX = regexp.MustCompile(`-----BEGIN ((?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?)-----`)
+
+// PEMDetector reports PEM-encoded private keys, such as RSA, EC, OpenSSH
+// and PGP private keys.
+type PEMDetector struct{}
+
+func (PEMDetector) Detect(content string) []Finding {
+	var findings []Finding
+
+	for _, loc := range pemRX.FindAllStringSubmatchIndex(content, -1) {
+		name := strings.ToLower(content[loc[2]:loc[3]])
+		findings = append(findings, Finding{Rule: "PEM " + name, Line: lineAt(content, loc[0])})
+	}
+
+	return findings
+}
+
+var pemEndRX = regexp.MustCompile(`-----END ((?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?)-----`)
+
+// pemBlocks() returns the first and last lines of each PEM-encoded private
+// key in content. A key without an END line runs to the end of content.
+func pemBlocks(content string) [][2]int {
+	var blocks [][2]int
+
+	for _, loc := range pemRX.FindAllStringIndex(content, -1) {
+		last := lineAt(content, len(content))
+		if end := pemEndRX.FindStringIndex(content[loc[1]:]); end != nil {
+			last = lineAt(content, loc[1]+end[0])
+		}
+		blocks = append(blocks, [2]int{lineAt(content, loc[0]), last})
+	}
+
+	return blocks
+}
+
+// lineAt() returns the line number of the byte at the given offset.
+func lineAt(content string, offset int) int {
+	return strings.Count(content[:offset], "\n") + 1
+}
@@ -0,0 +1,119 @@
+// Package tracing sets up OpenTelemetry tracing, exporting spans over
+// OTLP/HTTP to a collector, and ties log records to the traces they were
+// written in.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is reported as the name of the service every span came from.
+const ServiceName = "snippetbox"
+
+// tracesPath is where OTLP/HTTP collectors receive spans.
+const tracesPath = "/v1/traces"
+
+// Options describes where spans are sent, and how many.
+type Options struct {
+	// Endpoint is the collector's URL, e.g. http://localhost:4318. Spans are
+	// posted to /v1/traces unless the URL has a path of its own.
+	Endpoint string
+
+	// SampleRatio is the fraction of new traces to record, from 0 to 1.
+	// Traces started by a caller follow the caller's sampling decision.
+	SampleRatio float64
+
+	// Version is reported as the service's version.
+	Version string
+}
+
+// ParseEndpoint checks a collector URL, as given in Options.Endpoint.
+func ParseEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: invalid endpoint: %w", err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("tracing: endpoint %q must be an http:// or https:// URL", endpoint)
+	}
+
+	return u, nil
+}
+
+// New returns a TracerProvider which exports spans to the collector in the
+// background, in batches. Call its Shutdown method before exiting, to send
+// any spans still waiting.
+func New(ctx context.Context, opts Options) (*sdktrace.TracerProvider, error) {
+	u, err := ParseEndpoint(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(u.String())}
+	if u.Path == "" || u.Path == "/" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithURLPath(tracesPath))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	return NewProvider(sdktrace.NewBatchSpanProcessor(exporter), opts), nil
+}
+
+// NewProvider returns a TracerProvider which hands spans to processor.
+// Tests can use it with a synchronous processor and an in-memory exporter.
+func NewProvider(processor sdktrace.SpanProcessor, opts Options) *sdktrace.TracerProvider {
+	res := resource.NewSchemaless(
+		semconv.ServiceName(ServiceName),
+		semconv.ServiceVersion(opts.Version),
+	)
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+}
+
+// Propagator reads and writes trace context in the W3C traceparent and
+// tracestate headers.
+var Propagator propagation.TextMapPropagator = propagation.TraceContext{}
+
+// LogHandler wraps a slog.Handler to add the IDs of the current trace and
+// span to records logged with a context, such as by Logger.InfoContext.
+type LogHandler struct {
+	slog.Handler
+}
+
+func (h LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	sc := trace.SpanContextFromContext(ctx)
+	if sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return LogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h LogHandler) WithGroup(name string) slog.Handler {
+	return LogHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is a minimal OTLP/HTTP collector, which remembers the names of
+// the spans it receives.
+type collector struct {
+	mu    sync.Mutex
+	paths []string
+	spans []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req coltracepb.ExportTraceServiceRequest
+	err = proto.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.paths = append(c.paths, r.URL.Path)
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, span := range ss.Spans {
+				c.spans = append(c.spans, span.Name)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	resp, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	w.Write(resp)
+}
+
+func TestExport(t *testing.T) {
+	c := &collector{}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	tp, err := New(ctx, Options{Endpoint: ts.URL, SampleRatio: 1, Version: "test"})
+	assert.NilError(t, err)
+
+	tracer := tp.Tracer("test")
+	ctx, parent := tracer.Start(ctx, "parent")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+	parent.End()
+
+	// Shutting down sends the spans still waiting in the batch
+	err = tp.Shutdown(context.Background())
+	assert.NilError(t, err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	assert.Equal(t, len(c.paths) > 0, true)
+	assert.Equal(t, c.paths[0], "/v1/traces")
+	assert.Equal(t, len(c.spans), 2)
+	assert.Equal(t, c.spans[0], "child")
+	assert.Equal(t, c.spans[1], "parent")
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		valid    bool
+	}{
+		{"http://localhost:4318", true},
+		{"https://collector.example.com/otlp/v1/traces", true},
+		{"localhost:4318", false},
+		{"grpc://localhost:4317", false},
+		{"http://", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			_, err := ParseEndpoint(tt.endpoint)
+			assert.Equal(t, err == nil, tt.valid)
+		})
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(LogHandler{slog.NewTextHandler(&buf, nil)})
+
+	tp := NewProvider(tracetest.NewSpanRecorder(), Options{SampleRatio: 1})
+	ctx, span := tp.Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+
+	logger.InfoContext(ctx, "in a span")
+	assert.StringContains(t, buf.String(), "trace_id="+span.SpanContext().TraceID().String())
+	assert.StringContains(t, buf.String(), "span_id="+span.SpanContext().SpanID().String())
+
+	buf.Reset()
+	logger.Info("not in a span")
+	assert.Equal(t, bytes.Contains(buf.Bytes(), []byte("trace_id")), false)
+}
@@ -0,0 +1,107 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rhysmah/snippet-box/internal/assert"
+)
+
+func TestNotBlank(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"hello", true},
+		{"", false},
+		{"   ", false},
+		{"\t\n", false},
+		{" x ", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, NotBlank(tt.value), tt.want)
+	}
+}
+
+func TestMaxChars(t *testing.T) {
+	tests := []struct {
+		value string
+		n     int
+		want  bool
+	}{
+		{"", 0, true},
+		{"abc", 3, true},
+		{"abcd", 3, false},
+		{"日本語", 3, true}, // Counts characters, not bytes
+		{strings.Repeat("a", 101), 100, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, MaxChars(tt.value, tt.n), tt.want)
+	}
+}
+
+func TestMinChars(t *testing.T) {
+	tests := []struct {
+		value string
+		n     int
+		want  bool
+	}{
+		{"pa$$word", 8, true},
+		{"pa$$wor", 8, false},
+		{"日本語", 4, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, MinChars(tt.value, tt.n), tt.want)
+	}
+}
+
+func TestPermittedValued(t *testing.T) {
+	assert.Equal(t, PermittedValued(7, 1, 7, 365), true)
+	assert.Equal(t, PermittedValued(30, 1, 7, 365), false)
+	assert.Equal(t, PermittedValued("spam", "spam", "abuse"), true)
+	assert.Equal(t, PermittedValued("other"), false)
+}
+
+func TestMatchesEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"alice@example.com", true},
+		{"alice.smith+tag@mail.example.co.uk", true},
+		{"alice@localhost", true},
+		{"alice@", false},
+		{"@example.com", false},
+		{"alice@example.", false},
+		{"alice example@example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			assert.Equal(t, Matches(tt.email, EmailRX), tt.want)
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	var v Validator
+
+	assert.Equal(t, v.Valid(), true)
+
+	v.CheckField(true, "title", "should not be added")
+	assert.Equal(t, v.Valid(), true)
+
+	v.CheckField(false, "title", "Title cannot be blank")
+	v.CheckField(false, "title", "Title cannot exceed 100 characters")
+	assert.Equal(t, v.Valid(), false)
+
+	// Only the first error for each field is kept
+	assert.Equal(t, v.FieldErrors["title"], "Title cannot be blank")
+
+	var nonField Validator
+	nonField.AddNonFieldError("Email or password is incorrect")
+	assert.Equal(t, nonField.Valid(), false)
+}